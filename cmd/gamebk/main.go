@@ -1,16 +1,48 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"time"
 
 	"gamebk/internal/config"
 	"gamebk/internal/db"
+	"gamebk/internal/handler"
+	"gamebk/internal/model"
+	"gamebk/internal/pathvar"
+	"gamebk/internal/reconcile"
+	"gamebk/internal/repository"
 	"gamebk/internal/router"
 )
 
 func main() {
+	migrateDryRun := flag.Bool("migrate-dry-run", false, "list pending database migrations without applying them, then exit")
+	scan := flag.Bool("reconcile", false, "report missing and untracked backups as JSON, then exit")
+	prune := flag.Bool("prune", false, "with -reconcile, delete records whose backup folder is missing")
+	adopt := flag.Bool("adopt", false, "with -reconcile, register untracked backup folders as backups")
+	flag.Parse()
+
 	cfg := config.Load()
 
+	if *migrateDryRun {
+		pending, err := db.DryRunMigrations(cfg.DBPath)
+		if err != nil {
+			log.Fatalf("migration dry run failed: %v", err)
+		}
+		if len(pending) == 0 {
+			fmt.Printf("database is at schema version %d, nothing to migrate\n", db.LatestVersion())
+			return
+		}
+		for _, m := range pending {
+			fmt.Println(m)
+		}
+		return
+	}
+
 	dbConn, err := db.Open(cfg)
 	if err != nil {
 		log.Fatalf("db open failed: %v", err)
@@ -21,7 +53,31 @@ func main() {
 		}
 	}()
 
-	r := router.New(cfg, dbConn)
+	if *scan {
+		s := &reconcile.Scanner{
+			Repo:  repository.New(dbConn),
+			Paths: &pathvar.Resolver{SteamRoot: cfg.SteamRoot},
+			Actor: model.AuditActorCLI,
+		}
+		report, err := s.Scan(context.Background(), reconcile.Options{Prune: *prune, Adopt: *adopt})
+		if err != nil {
+			log.Fatalf("reconcile failed: %v", err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("reconcile failed: %v", err)
+		}
+		return
+	}
+
+	h := handler.New(cfg, dbConn)
+	if cfg.TrashDays > 0 {
+		// the handler's purger shares its job tracker with the API
+		go h.Trash.Run(context.Background(), time.Hour)
+	}
+
+	r := router.New(cfg, h)
 
 	addr := cfg.Addr()
 	log.Printf("server listening on %s", addr)
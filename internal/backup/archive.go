@@ -0,0 +1,352 @@
+package backup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Archive formats supported for bundles and imports.
+const (
+	FormatZip   = "zip"
+	FormatTarGz = "tar.gz"
+)
+
+// archiveEntry is one file or directory read from an archive.
+type archiveEntry struct {
+	Name    string
+	IsDir   bool
+	ModTime time.Time
+	Body    io.Reader
+}
+
+// IsArchive reports whether path names an archive ExtractArchive can unpack.
+func IsArchive(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// WriteTarGz streams all files under src to w as a gzip compressed tar archive.
+// Entry names are slash separated and relative to src.
+func WriteTarGz(w io.Writer, src string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("source is not a directory: %s", src)
+	}
+	aw, err := newArchiveWriter(w, FormatTarGz)
+	if err != nil {
+		return err
+	}
+	if err := writeTree(aw, src, ""); err != nil {
+		return err
+	}
+	return aw.Close()
+}
+
+// ExtractArchive unpacks a zip or tar.gz file into dst, which must not exist
+// yet. The format is detected from the leading bytes. Returns total bytes
+// written.
+func ExtractArchive(src, dst string) (int64, error) {
+	return extractInto(dst, func(fn func(e archiveEntry) error) error {
+		return readArchive(src, fn)
+	})
+}
+
+// ExtractTarGz unpacks a gzip compressed tar archive from r into dst, which
+// must not exist yet. Returns total bytes written.
+func ExtractTarGz(r io.Reader, dst string) (int64, error) {
+	return extractInto(dst, func(fn func(e archiveEntry) error) error {
+		return readTarGz(r, fn)
+	})
+}
+
+// ExtractZip unpacks a zip file into dst, which must not exist yet. Returns
+// total bytes written.
+func ExtractZip(path, dst string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+	return extractInto(dst, func(fn func(e archiveEntry) error) error {
+		return readZip(f, fn)
+	})
+}
+
+// extractInto creates dst and writes every entry produced by read below it.
+// Entries escaping dst are rejected and the partially extracted directory is
+// removed on failure.
+func extractInto(dst string, read func(fn func(e archiveEntry) error) error) (int64, error) {
+	if _, err := os.Stat(dst); err == nil {
+		return 0, fmt.Errorf("destination already exists: %s", dst)
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	err := read(func(e archiveEntry) error {
+		if e.IsDir && path.Clean(e.Name) == "." {
+			return nil
+		}
+		target, err := SafeJoin(dst, e.Name)
+		if err != nil {
+			return err
+		}
+		if e.IsDir {
+			return os.MkdirAll(target, 0o755)
+		}
+		n, err := writeFile(target, e.Body)
+		if err != nil {
+			return err
+		}
+		if !e.ModTime.IsZero() {
+			_ = os.Chtimes(target, e.ModTime, e.ModTime)
+		}
+		total += n
+		return nil
+	})
+	if err != nil {
+		_ = os.RemoveAll(dst)
+		return 0, err
+	}
+	return total, nil
+}
+
+// readArchive calls fn for every entry of a zip or tar.gz file, detected by
+// its leading bytes. Links and other special entries are rejected.
+func readArchive(path string, fn func(e archiveEntry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return fmt.Errorf("unrecognized archive: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK")):
+		return readZip(f, fn)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return readTarGz(bufio.NewReader(f), fn)
+	default:
+		return errors.New("unrecognized archive: expected zip or tar.gz")
+	}
+}
+
+func readZip(f *os.File, fn func(e archiveEntry) error) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return err
+	}
+	for _, zf := range zr.File {
+		mode := zf.Mode()
+		if mode.IsDir() {
+			if err := fn(archiveEntry{Name: zf.Name, IsDir: true, ModTime: zf.Modified}); err != nil {
+				return err
+			}
+			continue
+		}
+		if !mode.IsRegular() {
+			return fmt.Errorf("unsupported archive entry: %s", zf.Name)
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = fn(archiveEntry{Name: zf.Name, ModTime: zf.Modified, Body: rc})
+		_ = rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readTarGz(r io.Reader, fn func(e archiveEntry) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = gz.Close() }()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = fn(archiveEntry{Name: hdr.Name, IsDir: true, ModTime: hdr.ModTime})
+		case tar.TypeReg:
+			err = fn(archiveEntry{Name: hdr.Name, ModTime: hdr.ModTime, Body: tr})
+		default:
+			err = fmt.Errorf("unsupported archive entry: %s", hdr.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// archiveWriter writes entries in one of the supported formats.
+type archiveWriter interface {
+	addDir(name string, mod time.Time) error
+	addFile(name string, size int64, mod time.Time, r io.Reader) error
+	Close() error
+}
+
+func newArchiveWriter(w io.Writer, format string) (archiveWriter, error) {
+	switch format {
+	case FormatZip:
+		return &zipWriter{zw: zip.NewWriter(w)}, nil
+	case FormatTarGz:
+		gz := gzip.NewWriter(w)
+		return &tarGzWriter{gz: gz, tw: tar.NewWriter(gz)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %s", format)
+	}
+}
+
+// writeTree adds everything under src to aw, naming entries prefix followed
+// by the slash separated path relative to src. Symlinks are rejected.
+func writeTree(aw archiveWriter, src, prefix string) error {
+	src = resolveRoot(src)
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if d.Type()&os.ModeSymlink != 0 {
+			return fmt.Errorf("symlink not supported: %s", p)
+		}
+		name := prefix + filepath.ToSlash(rel)
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return aw.addDir(name, fi.ModTime())
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		return aw.addFile(name, fi.Size(), fi.ModTime(), f)
+	})
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (z *zipWriter) addDir(name string, mod time.Time) error {
+	_, err := z.zw.CreateHeader(&zip.FileHeader{Name: name + "/", Modified: mod})
+	return err
+}
+
+func (z *zipWriter) addFile(name string, size int64, mod time.Time, r io.Reader) error {
+	w, err := z.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: mod})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+func (z *zipWriter) Close() error {
+	return z.zw.Close()
+}
+
+type tarGzWriter struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func (t *tarGzWriter) addDir(name string, mod time.Time) error {
+	return t.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: 0o755, ModTime: mod})
+}
+
+func (t *tarGzWriter) addFile(name string, size int64, mod time.Time, r io.Reader) error {
+	if err := t.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: size, ModTime: mod}); err != nil {
+		return err
+	}
+	_, err := io.Copy(t.tw, r)
+	return err
+}
+
+func (t *tarGzWriter) Close() error {
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	return t.gz.Close()
+}
+
+// ErrUnsafePath is returned by SafeJoin for names escaping the root.
+var ErrUnsafePath = errors.New("unsafe path")
+
+// SafeJoin joins the slash separated relative name onto root and rejects
+// absolute names and names that would escape root.
+func SafeJoin(root, name string) (string, error) {
+	if strings.Contains(name, `\`) {
+		name = strings.ReplaceAll(name, `\`, "/")
+	}
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if clean == "." || filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" || strings.HasPrefix(name, "/") ||
+		clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	return filepath.Join(root, clean), nil
+}
+
+func writeFile(dst string, r io.Reader) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return 0, err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = out.Close() }()
+
+	n, err := io.Copy(out, r)
+	if err != nil {
+		return n, err
+	}
+	return n, out.Sync()
+}
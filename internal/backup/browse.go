@@ -0,0 +1,132 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrNotDir = errors.New("not a directory")
+
+type FileEntry struct {
+	Path  string `json:"path"`
+	Name  string `json:"name"`
+	IsDir bool   `json:"is_dir"`
+	// IsSymlink entries are listed but never followed or hashed.
+	IsSymlink bool      `json:"is_symlink,omitempty"`
+	SizeBytes int64     `json:"size_bytes"`
+	ModTime   time.Time `json:"mod_time"`
+	SHA256    string    `json:"sha256,omitempty"`
+}
+
+// ResolvePath maps a slash separated path relative to root onto the file
+// system, rejecting paths that escape root. An empty path or "." is root.
+func ResolvePath(root, rel string) (string, error) {
+	rel = strings.Trim(strings.ReplaceAll(rel, `\`, "/"), "/")
+	if rel == "" || path.Clean(rel) == "." {
+		return root, nil
+	}
+	return SafeJoin(root, rel)
+}
+
+// ResolveReal is ResolvePath with symlinks evaluated: it returns the real
+// root and the real path of rel, rejecting a path that a linked directory on
+// the way leads outside root.
+func ResolveReal(root, rel string) (string, string, error) {
+	abs, err := ResolvePath(root, rel)
+	if err != nil {
+		return "", "", err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", "", err
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", "", err
+	}
+	if !Within(realRoot, real) {
+		return "", "", fmt.Errorf("%w: %s leaves the backup", ErrUnsafePath, rel)
+	}
+	return realRoot, real, nil
+}
+
+// ListDir lists the entries of dir (relative to root), sorted with directories
+// first. With recursive, all descendants are returned. With hash, files carry
+// their sha256. Entry paths are relative to root. Symlinks are listed as such
+// and not walked into, and dir must not lead outside root.
+func ListDir(root, dir string, recursive, hash bool) ([]FileEntry, error) {
+	root, abs, err := ResolveReal(root, dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%w: %s", ErrNotDir, dir)
+	}
+
+	out := []FileEntry{}
+	err = filepath.WalkDir(abs, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if p == abs {
+			return nil
+		}
+		e, err := fileEntry(root, p, d, hash)
+		if err != nil {
+			return err
+		}
+		out = append(out, e)
+		if d.IsDir() && !recursive {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if !recursive && out[i].IsDir != out[j].IsDir {
+			return out[i].IsDir
+		}
+		return out[i].Path < out[j].Path
+	})
+	return out, nil
+}
+
+func fileEntry(root, p string, d fs.DirEntry, hash bool) (FileEntry, error) {
+	info, err := d.Info()
+	if err != nil {
+		return FileEntry{}, err
+	}
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	e := FileEntry{
+		Path:      filepath.ToSlash(rel),
+		Name:      d.Name(),
+		IsDir:     d.IsDir(),
+		IsSymlink: d.Type()&fs.ModeSymlink != 0,
+		ModTime:   info.ModTime().UTC(),
+	}
+	if !d.IsDir() && !e.IsSymlink {
+		e.SizeBytes = info.Size()
+		if hash && d.Type().IsRegular() {
+			if e.SHA256, _, err = HashFile(p); err != nil {
+				return FileEntry{}, err
+			}
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListDirSymlinkEscape(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "backup")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{filepath.Join(root, "sub"), filepath.Join(outside, "deep")} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{filepath.Join(root, "sub", "a.sav"), filepath.Join(outside, "secret"), filepath.Join(outside, "deep", "key")} {
+		if err := os.WriteFile(f, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "file-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"link", "link/deep", "sub/../link"} {
+		if _, err := ListDir(root, p, true, true); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("ListDir(%q) err = %v, want ErrUnsafePath", p, err)
+		}
+	}
+
+	entries, err := ListDir(root, "", true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]FileEntry{}
+	for _, e := range entries {
+		got[e.Path] = e
+	}
+	if len(got) != 4 {
+		t.Errorf("entries = %+v, want sub, sub/a.sav, link and file-link", entries)
+	}
+	for _, p := range []string{"link", "file-link"} {
+		e, ok := got[p]
+		if !ok || !e.IsSymlink || e.IsDir || e.SHA256 != "" {
+			t.Errorf("%s = %+v, want an unhashed symlink entry", p, e)
+		}
+	}
+	if got["sub/a.sav"].SHA256 == "" {
+		t.Error("regular file not hashed")
+	}
+
+	// a backup folder that is itself a link is listed through it
+	linked := filepath.Join(dir, "linked-backup")
+	if err := os.Symlink(root, linked); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := ListDir(linked, "sub", false, false); err != nil || len(entries) != 1 || entries[0].Path != "sub/a.sav" {
+		t.Errorf("ListDir through linked root = %+v, %v", entries, err)
+	}
+}
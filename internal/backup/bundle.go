@@ -0,0 +1,250 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A bundle is a zip or tar.gz archive holding a manifest at its root and the
+// backup files under data/. It is the format used to hand a backup to another
+// user or another gamebk instance.
+const (
+	BundleVersion      = 1
+	BundleManifestName = "gamebk-bundle.json"
+	bundleDataDir      = "data"
+)
+
+// ErrInvalidBundle is returned when a bundle is malformed, contains unsafe
+// paths or does not match its checksums.
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+type BundleFile struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+	SHA256    string `json:"sha256"`
+}
+
+type BundleManifest struct {
+	Version    int          `json:"version"`
+	GameName   string       `json:"game_name"`
+	BackupName string       `json:"backup_name"`
+	Profile    string       `json:"profile,omitempty"`
+	Notes      string       `json:"notes,omitempty"`
+	Tags       []string     `json:"tags,omitempty"`
+	Pinned     bool         `json:"pinned,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	SizeBytes  int64        `json:"size_bytes"`
+	Files      []BundleFile `json:"files"`
+}
+
+// Checksums hashes every regular file under dir. Paths are slash separated,
+// relative to dir and sorted. Returns the files and their total size.
+func Checksums(dir string) ([]BundleFile, int64, error) {
+	var out []BundleFile
+	var total int64
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("unsupported file type: %s", p)
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		sum, size, err := HashFile(p)
+		if err != nil {
+			return err
+		}
+		out = append(out, BundleFile{Path: filepath.ToSlash(rel), SizeBytes: size, SHA256: sum})
+		total += size
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out, total, nil
+}
+
+// HashFile returns the hex sha256 and size of a file.
+func HashFile(p string) (string, int64, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// WriteBundle streams src as a bundle in the given format. The manifest's
+// Version, Files and SizeBytes are filled in from src.
+func WriteBundle(w io.Writer, format, src string, m *BundleManifest) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("source is not a directory: %s", src)
+	}
+	files, total, err := Checksums(src)
+	if err != nil {
+		return err
+	}
+	m.Version = BundleVersion
+	m.Files = files
+	m.SizeBytes = total
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	aw, err := newArchiveWriter(w, format)
+	if err != nil {
+		return err
+	}
+	if err := aw.addFile(BundleManifestName, int64(len(manifest)), time.Now(), bytes.NewReader(manifest)); err != nil {
+		return err
+	}
+	if err := aw.addDir(bundleDataDir, info.ModTime()); err != nil {
+		return err
+	}
+
+	if err := writeTree(aw, src, bundleDataDir+"/"); err != nil {
+		return err
+	}
+	return aw.Close()
+}
+
+// ExtractBundle unpacks the bundle file at archivePath into dst, which must not
+// exist yet, and verifies every file against the manifest. Unsafe paths,
+// unexpected entries and checksum mismatches fail with ErrInvalidBundle; dst
+// is removed on any failure.
+func ExtractBundle(archivePath, dst string) (*BundleManifest, error) {
+	if _, err := os.Stat(dst); err == nil {
+		return nil, fmt.Errorf("destination already exists: %s", dst)
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return nil, err
+	}
+	m, err := extractBundle(archivePath, dst)
+	if err != nil {
+		_ = os.RemoveAll(dst)
+		return nil, err
+	}
+	return m, nil
+}
+
+func extractBundle(archivePath, dst string) (*BundleManifest, error) {
+	var m *BundleManifest
+	got := make(map[string]BundleFile)
+	err := readArchive(archivePath, func(e archiveEntry) error {
+		name := path.Clean(strings.ReplaceAll(e.Name, `\`, "/"))
+		if name == BundleManifestName && !e.IsDir {
+			var obj BundleManifest
+			if err := json.NewDecoder(e.Body).Decode(&obj); err != nil {
+				return fmt.Errorf("%w: bad manifest: %v", ErrInvalidBundle, err)
+			}
+			m = &obj
+			return nil
+		}
+		if name == bundleDataDir && e.IsDir {
+			return nil
+		}
+		rel, ok := strings.CutPrefix(name, bundleDataDir+"/")
+		if !ok {
+			return fmt.Errorf("%w: unexpected entry %s", ErrInvalidBundle, e.Name)
+		}
+		target, err := SafeJoin(dst, rel)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		if e.IsDir {
+			return os.MkdirAll(target, 0o755)
+		}
+		h := sha256.New()
+		n, err := writeFile(target, io.TeeReader(e.Body, h))
+		if err != nil {
+			return err
+		}
+		_ = os.Chtimes(target, e.ModTime, e.ModTime)
+		got[rel] = BundleFile{Path: rel, SizeBytes: n, SHA256: hex.EncodeToString(h.Sum(nil))}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if m == nil {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidBundle, BundleManifestName)
+	}
+	if m.Version < 1 || m.Version > BundleVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBundle, m.Version)
+	}
+	for _, f := range m.Files {
+		g, ok := got[path.Clean(f.Path)]
+		if !ok {
+			return nil, fmt.Errorf("%w: missing file %s", ErrInvalidBundle, f.Path)
+		}
+		if g.SizeBytes != f.SizeBytes || !strings.EqualFold(g.SHA256, f.SHA256) {
+			return nil, fmt.Errorf("%w: checksum mismatch for %s", ErrInvalidBundle, f.Path)
+		}
+		delete(got, path.Clean(f.Path))
+	}
+	for p := range got {
+		return nil, fmt.Errorf("%w: file %s not listed in manifest", ErrInvalidBundle, p)
+	}
+	return m, nil
+}
+
+// InstallBundle extracts and verifies the bundle at archivePath into
+// backupRoot/name. An empty name falls back to the backup name recorded in the
+// manifest. Returns the manifest and the installed backup path.
+func InstallBundle(archivePath, backupRoot, name string) (*BundleManifest, string, error) {
+	staging, err := os.MkdirTemp(backupRoot, ".bundle-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	data := filepath.Join(staging, "data")
+	m, err := ExtractBundle(archivePath, data)
+	if err != nil {
+		return nil, "", err
+	}
+	if name == "" {
+		name = m.BackupName
+	}
+	if err := ValidateName(name); err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	backupPath := filepath.Join(backupRoot, name)
+	if _, err := os.Stat(backupPath); err == nil {
+		return nil, "", fmt.Errorf("destination already exists: %s", backupPath)
+	}
+	if err := os.Rename(data, backupPath); err != nil {
+		return nil, "", err
+	}
+	return m, backupPath, nil
+}
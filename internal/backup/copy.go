@@ -11,6 +11,7 @@ import (
 // CopyDir copies all files under src to dst, preserving directory structure.
 // Returns total bytes copied.
 func CopyDir(src, dst string) (int64, error) {
+	src = resolveRoot(src)
 	info, err := os.Stat(src)
 	if err != nil {
 		return 0, err
@@ -63,6 +64,7 @@ func CopyDir(src, dst string) (int64, error) {
 // CopyDirInto copies all files under src into dst (dst can already exist).
 // Returns total bytes copied.
 func CopyDirInto(src, dst string) (int64, error) {
+	src = resolveRoot(src)
 	info, err := os.Stat(src)
 	if err != nil {
 		return 0, err
@@ -144,3 +146,36 @@ func copyFile(src, dst string) (int64, error) {
 	}
 	return n, out.Sync()
 }
+
+// DirSize returns the total size of regular files under dir.
+func DirSize(dir string) (int64, error) {
+	dir = resolveRoot(dir)
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// resolveRoot follows a symlink or junction at dir itself, so walking it lists
+// the directory it points to. Links below dir are not followed.
+func resolveRoot(dir string) string {
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		return real
+	}
+	return dir
+}
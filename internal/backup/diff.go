@@ -0,0 +1,128 @@
+package backup
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+type FileChange struct {
+	Path      string `json:"path"`
+	OldSize   int64  `json:"old_size"`
+	NewSize   int64  `json:"new_size"`
+	SizeDelta int64  `json:"size_delta"`
+}
+
+// Diff describes how target differs from base: Added files exist only in
+// target, Removed only in base, Modified in both with different content.
+type Diff struct {
+	Added     []FileChange `json:"added"`
+	Removed   []FileChange `json:"removed"`
+	Modified  []FileChange `json:"modified"`
+	Unchanged int          `json:"unchanged"`
+	SizeDelta int64        `json:"size_delta"`
+}
+
+// DiffDirs compares the regular files under base and target. Files of equal
+// size are compared by sha256. A missing base is treated as empty. When scope
+// is set, only files within it (see Scope) are compared.
+func DiffDirs(base, target string, scope *Scope) (*Diff, error) {
+	baseFiles, err := fileSizes(base)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	targetFiles, err := fileSizes(target)
+	if err != nil {
+		return nil, err
+	}
+	if scope != nil {
+		for _, files := range []map[string]int64{baseFiles, targetFiles} {
+			for rel := range files {
+				if !scope.Contains(rel) {
+					delete(files, rel)
+				}
+			}
+		}
+	}
+
+	d := &Diff{Added: []FileChange{}, Removed: []FileChange{}, Modified: []FileChange{}}
+	for rel, newSize := range targetFiles {
+		oldSize, ok := baseFiles[rel]
+		if !ok {
+			d.Added = append(d.Added, change(rel, 0, newSize))
+			continue
+		}
+		same := oldSize == newSize
+		if same {
+			same, err = sameContent(filepath.Join(base, filepath.FromSlash(rel)), filepath.Join(target, filepath.FromSlash(rel)))
+			if err != nil {
+				return nil, err
+			}
+		}
+		if same {
+			d.Unchanged++
+		} else {
+			d.Modified = append(d.Modified, change(rel, oldSize, newSize))
+		}
+	}
+	for rel, oldSize := range baseFiles {
+		if _, ok := targetFiles[rel]; !ok {
+			d.Removed = append(d.Removed, change(rel, oldSize, 0))
+		}
+	}
+
+	for _, list := range [][]FileChange{d.Added, d.Removed, d.Modified} {
+		sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+		for _, c := range list {
+			d.SizeDelta += c.SizeDelta
+		}
+	}
+	return d, nil
+}
+
+func change(rel string, oldSize, newSize int64) FileChange {
+	return FileChange{Path: rel, OldSize: oldSize, NewSize: newSize, SizeDelta: newSize - oldSize}
+}
+
+func sameContent(a, b string) (bool, error) {
+	ha, _, err := HashFile(a)
+	if err != nil {
+		return false, err
+	}
+	hb, _, err := HashFile(b)
+	if err != nil {
+		return false, err
+	}
+	return ha == hb, nil
+}
+
+// fileSizes maps the slash separated relative path of every regular file
+// under dir to its size.
+func fileSizes(dir string) (map[string]int64, error) {
+	dir = resolveRoot(dir)
+	if _, err := os.Stat(dir); err != nil {
+		return map[string]int64{}, err
+	}
+	out := make(map[string]int64)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		out[filepath.ToSlash(rel)] = info.Size()
+		return nil
+	})
+	return out, err
+}
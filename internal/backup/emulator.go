@@ -0,0 +1,280 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Emulator games keep their saves in a directory shared by many ROMs. Only
+// the entries in the game's scope belong to it: files named after its ROM and
+// entries matching its save patterns. Patterns use path.Match syntax relative
+// to the shared directory and a matched directory stands for its whole
+// subtree.
+
+// Scope selects the saves of an emulator game in a shared directory. A nil
+// *Scope stands for a game that owns its whole save directory.
+type Scope struct {
+	// RomName selects the files named after the ROM with a single extension
+	// ("<rom>.srm", "<rom>.state"), directly in the save directory or one
+	// level below it, as RetroArch's per-core and Dolphin/PCSX2 style
+	// per-type folders lay them out.
+	RomName string
+	// Patterns are additional globs.
+	Patterns []string
+}
+
+// EmulatorScope returns the scope of an emulator game.
+func EmulatorScope(romName string, patterns []string) *Scope {
+	return &Scope{RomName: romName, Patterns: patterns}
+}
+
+// ValidatePatterns checks that every pattern is a relative, well-formed glob.
+func ValidatePatterns(patterns []string) error {
+	for _, raw := range patterns {
+		p := strings.Trim(strings.ReplaceAll(raw, `\`, "/"), "/")
+		if p == "" {
+			return fmt.Errorf("%w: empty pattern", ErrUnsafePath)
+		}
+		if _, err := SafeJoin("root", p); err != nil {
+			return err
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", raw, err)
+		}
+	}
+	return nil
+}
+
+// Contains reports whether rel or one of its parent directories is in the
+// scope. A nil scope contains every path.
+func (s *Scope) Contains(rel string) bool {
+	if s == nil {
+		return true
+	}
+	for p := rel; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		if s.matches(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// foldNames is set where the usual file systems ignore case, so ROM names
+// and patterns match files the way the file system would open them.
+var foldNames = runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+
+// foldName returns name as file names are compared on this platform.
+func foldName(name string) string {
+	if foldNames {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// matches reports whether rel itself is in the scope.
+func (s *Scope) matches(rel string) bool {
+	if s.RomName != "" && strings.Count(rel, "/") <= 1 && isRomFile(path.Base(rel), s.RomName) {
+		return true
+	}
+	for _, raw := range s.Patterns {
+		pattern := strings.Trim(strings.ReplaceAll(raw, `\`, "/"), "/")
+		if ok, _ := path.Match(foldName(pattern), foldName(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isRomFile reports whether name is the ROM name itself or the ROM name plus
+// one extension. "Super Mario Bros. 3.srm" is not a save of "Super Mario Bros".
+func isRomFile(name, rom string) bool {
+	rest, ok := strings.CutPrefix(foldName(name), foldName(rom))
+	if !ok {
+		return false
+	}
+	if rest == "" {
+		return true
+	}
+	dot, ext, _ := strings.Cut(rest, ".")
+	return dot == "" && ext != "" && !strings.ContainsAny(ext, ". ")
+}
+
+// ScopesOverlap reports whether two emulator games saving to dir would claim
+// the same saves: they have the same ROM name or save pattern, or an entry
+// already in dir is in both scopes.
+func ScopesOverlap(dir string, a, b *Scope) (bool, error) {
+	if a == nil || b == nil {
+		return true, nil
+	}
+	if a.RomName != "" && foldName(a.RomName) == foldName(b.RomName) {
+		return true, nil
+	}
+	for _, p := range a.Patterns {
+		if slices.ContainsFunc(b.Patterns, func(q string) bool { return foldName(p) == foldName(q) }) {
+			return true, nil
+		}
+	}
+	overlap := false
+	err := filepath.WalkDir(resolveRoot(dir), func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(resolveRoot(dir), p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if a.Contains(rel) && b.Contains(rel) {
+			overlap = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	return overlap, nil
+}
+
+// Match returns the topmost entries under root in the scope; a matched
+// directory stands for its whole subtree. ErrNoMatch is returned when nothing
+// matches.
+func (s *Scope) Match(root string) ([]string, error) {
+	if err := ValidatePatterns(s.Patterns); err != nil {
+		return nil, err
+	}
+	root = resolveRoot(root)
+	var rels []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !s.matches(rel) {
+			return nil
+		}
+		rels = append(rels, rel)
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(rels) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoMatch, s)
+	}
+	sort.Strings(rels)
+	return rels, nil
+}
+
+// String describes the scope for error messages.
+func (s *Scope) String() string {
+	parts := append([]string(nil), s.Patterns...)
+	if s.RomName != "" {
+		parts = append([]string{"rom " + s.RomName}, parts...)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// CopySelected copies the relative paths rels from src into dst, which must
+// not exist yet. dst is removed again when copying fails. Returns total bytes
+// copied.
+func CopySelected(src, dst string, rels []string) (int64, error) {
+	if _, err := os.Stat(dst); err == nil {
+		return 0, fmt.Errorf("destination already exists: %s", dst)
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return 0, err
+	}
+	n, err := RestorePaths(src, dst, rels)
+	if err != nil {
+		_ = os.RemoveAll(dst)
+		return 0, err
+	}
+	return n, nil
+}
+
+// RestoreSelected replaces the files of dst in scope with the backup at src:
+// every file of the backup is restored and files in scope missing from the
+// backup are removed. Other files of the shared directory are left alone.
+// Returns the restored relative paths.
+func RestoreSelected(src, dst string, scope *Scope) ([]string, error) {
+	backupFiles, err := fileSizes(src)
+	if err != nil {
+		return nil, err
+	}
+	live, err := fileSizes(dst)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	rels := make([]string, 0, len(backupFiles))
+	restored := make(map[string]bool, len(backupFiles))
+	for rel := range backupFiles {
+		rels = append(rels, rel)
+		restored[foldName(rel)] = true
+	}
+	sort.Strings(rels)
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return nil, err
+	}
+	if _, err := RestorePaths(src, dst, rels); err != nil {
+		return nil, err
+	}
+
+	for rel := range live {
+		// where case is ignored, game.srm is the Game.SRM just restored
+		if restored[foldName(rel)] || !scope.Contains(rel) {
+			continue
+		}
+		p, err := SafeJoin(dst, rel)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return rels, nil
+}
+
+// RemoveSelected deletes the files of dir in scope and leaves the rest of the
+// shared directory alone. Returns the removed relative paths.
+func RemoveSelected(dir string, scope *Scope) ([]string, error) {
+	live, err := fileSizes(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var removed []string
+	for rel := range live {
+		if !scope.Contains(rel) {
+			continue
+		}
+		p, err := SafeJoin(dir, rel)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		removed = append(removed, rel)
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
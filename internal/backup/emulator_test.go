@@ -0,0 +1,37 @@
+package backup
+
+import "testing"
+
+func TestScopeCase(t *testing.T) {
+	defer func(v bool) { foldNames = v }(foldNames)
+	tests := []struct {
+		name string
+		fold bool
+		a, b *Scope
+		rel  string
+		// in reports whether rel is in scope a; overlap whether a and b
+		// claim the same saves
+		in, overlap bool
+	}{
+		{name: "rom name, case kept", fold: false, a: EmulatorScope("Game", nil), b: EmulatorScope("game", nil), rel: "game.srm", in: false, overlap: false},
+		{name: "rom name, case ignored", fold: true, a: EmulatorScope("Game", nil), b: EmulatorScope("game", nil), rel: "game.srm", in: true, overlap: true},
+		{name: "pattern, case kept", fold: false, a: EmulatorScope("", []string{"*.SRM"}), b: EmulatorScope("", []string{"*.srm"}), rel: "x.srm", in: false, overlap: false},
+		{name: "pattern, case ignored", fold: true, a: EmulatorScope("", []string{"*.SRM"}), b: EmulatorScope("", []string{"*.srm"}), rel: "x.srm", in: true, overlap: true},
+		{name: "other rom", fold: true, a: EmulatorScope("Game", nil), b: EmulatorScope("Game 2", nil), rel: "game 2.srm", in: false, overlap: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			foldNames = tt.fold
+			if got := tt.a.Contains(tt.rel); got != tt.in {
+				t.Errorf("Contains(%q) = %v, want %v", tt.rel, got, tt.in)
+			}
+			got, err := ScopesOverlap(t.TempDir(), tt.a, tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.overlap {
+				t.Errorf("ScopesOverlap = %v, want %v", got, tt.overlap)
+			}
+		})
+	}
+}
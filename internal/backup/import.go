@@ -0,0 +1,92 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// nameTimeLayouts are timestamp formats commonly used for backup folder names,
+// including the default name produced by BackupGame.
+var nameTimeLayouts = []string{
+	"20060102_150405",
+	"20060102-150405",
+	"20060102150405",
+	"2006-01-02_15-04-05",
+	"2006-01-02 15-04-05",
+	"2006-01-02 15.04.05",
+	"2006-01-02T15-04-05",
+	"2006-01-02_15.04.05",
+	"2006-01-02",
+	"20060102",
+}
+
+var nameTimeRe = regexp.MustCompile(`\d{4}-?\d{2}-?\d{2}(?:[ _T-]?\d{2}[-.]?\d{2}[-.]?\d{2})?`)
+
+// TimeFromName extracts a timestamp embedded in a folder name such as
+// "20260128_213045" or "save 2024-03-01 10.15.00", in local time.
+func TimeFromName(name string) (time.Time, bool) {
+	for _, m := range nameTimeRe.FindAllString(name, -1) {
+		for _, layout := range nameTimeLayouts {
+			if len(m) != len(layout) {
+				continue
+			}
+			if t, err := time.ParseInLocation(layout, m, time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// MoveDir renames src to dst, falling back to copy and delete when they are on
+// different volumes.
+func MoveDir(src, dst string) (int64, error) {
+	if _, err := os.Stat(dst); err == nil {
+		return 0, fmt.Errorf("destination already exists: %s", dst)
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return DirSize(dst)
+	}
+	size, err := CopyDir(src, dst)
+	if err != nil {
+		_ = os.RemoveAll(dst)
+		return 0, err
+	}
+	return size, os.RemoveAll(src)
+}
+
+// SubDirs returns the immediate sub-directories of dir, sorted by name.
+func SubDirs(dir string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []os.DirEntry
+	for _, e := range entries {
+		if e.IsDir() {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// absPath cleans p and makes it absolute.
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
+
+// Within reports whether path is root or lies inside root.
+func Within(root, path string) bool {
+	rel, err := filepath.Rel(absPath(root), absPath(path))
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -0,0 +1,265 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// freeSpace is FreeSpace, replaced in tests.
+var freeSpace = FreeSpace
+
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// RestorePlan lists what a restore would do to the live save, relative paths
+// slash separated, without touching the file system. Delete includes
+// directories, symlinks and other entries a restore removes, not only files.
+type RestorePlan struct {
+	OK             bool     `json:"ok"`
+	Checks         []Check  `json:"checks"`
+	Paths          []string `json:"paths,omitempty"`
+	Delete         []string `json:"delete"`
+	Overwrite      []string `json:"overwrite"`
+	Create         []string `json:"create"`
+	BytesToWrite   int64    `json:"bytes_to_write"`
+	BytesToRemove  int64    `json:"bytes_to_remove"`
+	BytesAvailable uint64   `json:"bytes_available"`
+}
+
+// PlanRestore runs the checks a restore of backupPath onto gamePath depends on
+// and computes the files it would delete, overwrite and create. paths limits
+// the plan to a partial restore as in MatchPaths; scope limits the live files
+// it may delete to those of an emulator game (see Scope). Invalid paths are
+// returned as errors; failed checks are reported in the plan.
+func PlanRestore(backupPath, gamePath string, paths []string, scope *Scope) (*RestorePlan, error) {
+	plan := &RestorePlan{Delete: []string{}, Overwrite: []string{}, Create: []string{}}
+	add := func(name string, err error, detail string) bool {
+		c := Check{Name: name, OK: err == nil, Detail: detail}
+		if err != nil {
+			c.Detail = err.Error()
+		}
+		plan.Checks = append(plan.Checks, c)
+		return err == nil
+	}
+
+	info, err := os.Stat(backupPath)
+	if err == nil && !info.IsDir() {
+		err = errors.New("backup path is not a directory")
+	}
+	if !add("backup_exists", err, backupPath) {
+		plan.finish()
+		return plan, nil
+	}
+
+	if len(paths) > 0 {
+		rels, err := MatchPaths(backupPath, paths)
+		if err != nil {
+			return nil, err
+		}
+		plan.Paths = rels
+	}
+
+	backupFiles, err := fileSizes(backupPath)
+	if !add("backup_readable", firstErr(err, verifyReadable(backupPath, backupFiles)), "") {
+		plan.finish()
+		return plan, nil
+	}
+	live, err := liveEntries(gamePath, scope)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		add("game_path_readable", err, "")
+		plan.finish()
+		return plan, nil
+	}
+	backupDirs, err := dirSet(backupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for rel, size := range backupFiles {
+		if !plan.covers(rel) {
+			continue
+		}
+		plan.BytesToWrite += size
+		if liveSize, ok := live[rel]; ok {
+			plan.Overwrite = append(plan.Overwrite, rel)
+			if liveSize > 0 {
+				plan.BytesToRemove += liveSize
+			}
+		} else {
+			plan.Create = append(plan.Create, rel)
+		}
+	}
+	for rel, size := range live {
+		if !plan.covers(rel) {
+			continue
+		}
+		if _, ok := backupFiles[rel]; ok {
+			continue
+		}
+		if size < 0 && backupDirs[rel] {
+			// a directory the backup has too
+			continue
+		}
+		plan.Delete = append(plan.Delete, rel)
+		if size > 0 {
+			plan.BytesToRemove += size
+		}
+	}
+	sort.Strings(plan.Delete)
+	sort.Strings(plan.Overwrite)
+	sort.Strings(plan.Create)
+
+	dir := existingAncestor(gamePath)
+	add("game_path_writable", checkWritable(dir), dir)
+
+	free, err := freeSpace(dir)
+	if err == nil {
+		plan.BytesAvailable = free
+		if need := plan.spaceNeeded(scope); need > 0 && uint64(need) > free {
+			err = fmt.Errorf("need %d more bytes, %d available", need, free)
+		}
+	}
+	add("disk_space", err, "")
+
+	plan.finish()
+	return plan, nil
+}
+
+func (p *RestorePlan) finish() {
+	p.OK = true
+	for _, c := range p.Checks {
+		if !c.OK {
+			p.OK = false
+		}
+	}
+}
+
+// spaceNeeded returns the free space the restore needs at its peak. A full
+// restore copies the whole backup beside the save before clearing it (see
+// ReplaceDir), so it needs every byte it writes; partial and emulator
+// restores replace entries in place and only need the difference.
+func (p *RestorePlan) spaceNeeded(scope *Scope) int64 {
+	if len(p.Paths) == 0 && scope == nil {
+		return p.BytesToWrite
+	}
+	return p.BytesToWrite - p.BytesToRemove
+}
+
+// covers reports whether rel is part of the restore: everything for a full
+// restore, otherwise the matched paths and their subtrees.
+func (p *RestorePlan) covers(rel string) bool {
+	if len(p.Paths) == 0 {
+		return true
+	}
+	for _, m := range p.Paths {
+		if rel == m || strings.HasPrefix(rel, m+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyReadable opens every file of the backup to make sure it can be copied.
+func verifyReadable(root string, files map[string]int64) error {
+	for rel := range files {
+		f, err := os.Open(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		_ = f.Close()
+	}
+	return nil
+}
+
+// liveEntries maps what a restore may remove from gamePath to its size. A full
+// or partial restore replaces whole directories, so every entry counts:
+// files, directories (size -1), symlinks and others. Within an emulator
+// scope only regular files are removed, as RestoreSelected does.
+func liveEntries(gamePath string, scope *Scope) (map[string]int64, error) {
+	if scope != nil {
+		files, err := fileSizes(gamePath)
+		for rel := range files {
+			if !scope.Contains(rel) {
+				delete(files, rel)
+			}
+		}
+		return files, err
+	}
+	root := resolveRoot(gamePath)
+	if _, err := os.Stat(root); err != nil {
+		return map[string]int64{}, err
+	}
+	out := make(map[string]int64)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		switch {
+		case d.IsDir():
+			out[rel] = -1
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			out[rel] = info.Size()
+		default:
+			out[rel] = 0
+		}
+		return nil
+	})
+	return out, err
+}
+
+// dirSet returns the relative paths of the directories under dir.
+func dirSet(dir string) (map[string]bool, error) {
+	out := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil || !d.IsDir() {
+			return walkErr
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		out[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	return out, err
+}
+
+// existingAncestor returns p or its nearest existing parent directory.
+func existingAncestor(p string) string {
+	for {
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			return p
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return p
+		}
+		p = parent
+	}
+}
+
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlanRestoreDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "backup")
+	dst := filepath.Join(dir, "save")
+	for p, size := range map[string]int{
+		filepath.Join(src, "a.sav"): 100,
+		filepath.Join(dst, "a.sav"): 80,
+	} {
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(strings.Repeat("x", size)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// between the net change (20) and the bytes written (100)
+	defer func(f func(string) (uint64, error)) { freeSpace = f }(freeSpace)
+	freeSpace = func(string) (uint64, error) { return 50, nil }
+
+	tests := []struct {
+		name  string
+		paths []string
+		scope *Scope
+		ok    bool
+	}{
+		{name: "full restore stages a full copy", ok: false},
+		{name: "partial restore replaces in place", paths: []string{"a.sav"}, ok: true},
+		{name: "emulator restore replaces in place", scope: EmulatorScope("a", nil), ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := PlanRestore(src, dst, tt.paths, tt.scope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plan.BytesToWrite != 100 || plan.BytesToRemove != 80 {
+				t.Fatalf("bytes = %d/%d, want 100/80", plan.BytesToWrite, plan.BytesToRemove)
+			}
+			for _, c := range plan.Checks {
+				if c.Name == "disk_space" && c.OK != tt.ok {
+					t.Errorf("disk_space ok = %v, want %v (%s)", c.OK, tt.ok, c.Detail)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNoMatch is returned by MatchPaths when a pattern matches nothing.
+var ErrNoMatch = errors.New("no entries match")
+
+// MatchPaths resolves relative paths or glob patterns (path.Match syntax,
+// slash separated) against the entries under root. A matched directory stands
+// for its whole subtree, so entries below another match are dropped. Every
+// pattern must match at least one entry.
+func MatchPaths(root string, patterns []string) ([]string, error) {
+	return matchPaths(root, patterns, true)
+}
+
+func matchPaths(root string, patterns []string, strict bool) ([]string, error) {
+	root = resolveRoot(root)
+	var all []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			all = append(all, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make(map[string]bool)
+	for _, raw := range patterns {
+		pattern := strings.Trim(strings.ReplaceAll(raw, `\`, "/"), "/")
+		if pattern == "" {
+			return nil, fmt.Errorf("%w: empty pattern", ErrUnsafePath)
+		}
+		if _, err := SafeJoin(root, pattern); err != nil {
+			return nil, err
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", raw, err)
+		}
+		found := false
+		for _, rel := range all {
+			if ok, _ := path.Match(pattern, rel); ok {
+				matched[rel] = true
+				found = true
+			}
+		}
+		if strict && !found {
+			return nil, fmt.Errorf("%w: %s", ErrNoMatch, raw)
+		}
+	}
+
+	out := make([]string, 0, len(matched))
+	for rel := range matched {
+		out = append(out, rel)
+	}
+	sort.Strings(out)
+	// drop entries already covered by a matched parent directory
+	kept := out[:0]
+	for _, rel := range out {
+		if len(kept) > 0 && strings.HasPrefix(rel, kept[len(kept)-1]+"/") {
+			continue
+		}
+		kept = append(kept, rel)
+	}
+	return kept, nil
+}
+
+// RestorePaths replaces each relative path under dst with its counterpart
+// under src. Directories are replaced as a whole; everything else under dst
+// is left untouched. Returns total bytes copied.
+func RestorePaths(src, dst string, rels []string) (int64, error) {
+	var total int64
+	for _, rel := range rels {
+		from, err := SafeJoin(src, rel)
+		if err != nil {
+			return total, err
+		}
+		to, err := SafeJoin(dst, rel)
+		if err != nil {
+			return total, err
+		}
+		info, err := os.Stat(from)
+		if err != nil {
+			return total, err
+		}
+		if err := os.RemoveAll(to); err != nil {
+			return total, err
+		}
+		var n int64
+		if info.IsDir() {
+			n, err = CopyDir(from, to)
+		} else {
+			n, err = copyFile(from, to)
+		}
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// ReplaceDir replaces the contents of dst with a copy of src. The copy is
+// written to a staging directory first, so a failed copy leaves dst untouched;
+// then dst is cleared and the copy moved in. dst itself is kept, so a save
+// directory that is a symlink or junction stays one, and one held open by
+// another process can still be restored into. Returns total bytes copied.
+func ReplaceDir(src, dst string) (int64, error) {
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return 0, err
+	}
+	// stage beside the real directory so the final renames stay on one volume
+	real, err := filepath.EvalSymlinks(dst)
+	if err != nil {
+		return 0, err
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(real), "."+filepath.Base(real)+".restore-")
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	data := filepath.Join(staging, "data")
+	size, err := CopyDir(src, data)
+	if err != nil {
+		return 0, err
+	}
+	if err := ClearDir(real); err != nil {
+		return 0, err
+	}
+	entries, err := os.ReadDir(data)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if err := os.Rename(filepath.Join(data, e.Name()), filepath.Join(real, e.Name())); err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
@@ -0,0 +1,93 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// maxNameLen is the longest backup folder name accepted, in bytes.
+const maxNameLen = 200
+
+// ErrPathOverlap is returned when a game's save directory and backup root
+// contain one another.
+var ErrPathOverlap = errors.New("game_path and backup_root overlap")
+
+// reservedNames are device names Windows refuses as file names, with or
+// without an extension.
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// ValidateName checks that name can be used as a single backup folder name on
+// every supported platform. Names starting with a dot are reserved for the
+// staging directories created inside a backup root.
+func ValidateName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("backup name is empty")
+	case len(name) > maxNameLen:
+		return fmt.Errorf("backup name is longer than %d bytes", maxNameLen)
+	case strings.ContainsAny(name, `/\`) || filepath.VolumeName(name) != "":
+		return fmt.Errorf("backup name must not contain path separators: %s", name)
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("backup name must not start with a dot: %s", name)
+	case strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") || strings.HasPrefix(name, " "):
+		return fmt.Errorf("backup name must not start or end with a space or end with a dot: %s", name)
+	case strings.ContainsAny(name, `<>:"|?*`):
+		return fmt.Errorf(`backup name must not contain any of <>:"|?*: %s`, name)
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f {
+			return fmt.Errorf("backup name must not contain control characters: %q", name)
+		}
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if reservedNames[strings.ToUpper(base)] {
+		return fmt.Errorf("backup name is reserved: %s", name)
+	}
+	return nil
+}
+
+// ValidateGamePaths checks that both paths are absolute and that neither lies
+// inside the other: a backup root inside the save directory would make every
+// backup contain the earlier ones, and a save directory inside the backup root
+// would be mistaken for a backup.
+func ValidateGamePaths(gamePath, backupRoot string) error {
+	if !filepath.IsAbs(gamePath) {
+		return fmt.Errorf("game_path must be absolute: %s", gamePath)
+	}
+	if !filepath.IsAbs(backupRoot) {
+		return fmt.Errorf("backup_root must be absolute: %s", backupRoot)
+	}
+	if Within(gamePath, backupRoot) || Within(backupRoot, gamePath) {
+		return ErrPathOverlap
+	}
+	return nil
+}
+
+// SanitizeName turns an arbitrary title into a name accepted by ValidateName,
+// replacing unsupported characters with underscores.
+func SanitizeName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, title)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	if len(name) > maxNameLen {
+		name = name[:maxNameLen]
+	}
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+	if base, _, _ := strings.Cut(name, "."); reservedNames[strings.ToUpper(base)] {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package backup
+
+import "golang.org/x/sys/unix"
+
+// checkWritable reports whether the current user may create and delete
+// entries in dir, without writing anything.
+func checkWritable(dir string) error {
+	return unix.Access(dir, unix.W_OK|unix.X_OK)
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+package backup
+
+import "golang.org/x/sys/windows"
+
+// Directory access rights, not defined by x/sys/windows.
+const (
+	fileAddFile         = 0x0002
+	fileAddSubdirectory = 0x0004
+	fileDeleteChild     = 0x0040
+)
+
+// checkWritable reports whether the current user may create and delete
+// entries in dir, without writing anything: opening the directory with those
+// rights is checked against its ACL. Every share mode is granted, so a game
+// holding the directory open is not disturbed.
+func checkWritable(dir string) error {
+	p, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return err
+	}
+	h, err := windows.CreateFile(p, fileAddFile|fileAddSubdirectory|fileDeleteChild,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return err
+	}
+	return windows.CloseHandle(h)
+}
@@ -2,19 +2,42 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 type Config struct {
 	Host   string
 	Port   string
 	DBPath string
+	// SteamRoot overrides the detected Steam installation used for <steam>.
+	SteamRoot string
+	// ManifestPath is the Ludusavi-format game manifest used for detection.
+	ManifestPath string
+	// IconDir holds the uploaded game icons.
+	IconDir string
+	// TrashDays is how long deleted backups stay in the trash before they
+	// are purged; 0 keeps them until purged by hand.
+	TrashDays int
+	// TrustedProxies are the proxy addresses or CIDRs whose X-Forwarded-For
+	// is believed for the client IP; none by default.
+	TrustedProxies []string
 }
 
 func Load() Config {
+	dbPath := envOrDefault("GAMEBK_DB_PATH", "./data/gamebk.db")
 	return Config{
 		Host:   envOrDefault("GAMEBK_HOST", "0.0.0.0"),
 		Port:   envOrDefault("GAMEBK_PORT", "8080"),
-		DBPath: envOrDefault("GAMEBK_DB_PATH", "./data/gamebk.db"),
+		DBPath: dbPath,
+
+		SteamRoot:    envOrDefault("GAMEBK_STEAM_ROOT", ""),
+		ManifestPath: envOrDefault("GAMEBK_MANIFEST_PATH", filepath.Join(filepath.Dir(dbPath), "manifest.yaml")),
+		IconDir:      envOrDefault("GAMEBK_ICON_DIR", filepath.Join(filepath.Dir(dbPath), "icons")),
+		TrashDays:    envIntOrDefault("GAMEBK_TRASH_DAYS", 30),
+
+		TrustedProxies: envList("GAMEBK_TRUSTED_PROXIES"),
 	}
 }
 
@@ -22,6 +45,27 @@ func (c Config) Addr() string {
 	return c.Host + ":" + c.Port
 }
 
+// envIntOrDefault reads a non-negative integer, falling back to def when the
+// variable is unset or invalid.
+func envIntOrDefault(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || v < 0 {
+		return def
+	}
+	return v
+}
+
+// envList reads a comma separated list, skipping empty items.
+func envList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 func envOrDefault(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -15,6 +15,14 @@ const (
 	bucketMeta    = "meta"
 	bucketGames   = "games"
 	bucketBackups = "backups"
+
+	bucketBackupIndex = "backups_by_game"
+
+	bucketPeers       = "peers"
+	bucketSyncStates  = "sync_states"
+	bucketTrash       = "trash"
+	bucketAudit       = "audit"
+	bucketGameHistory = "game_history"
 )
 
 func Open(cfg config.Config) (*bbolt.DB, error) {
@@ -30,7 +38,7 @@ func Open(cfg config.Config) (*bbolt.DB, error) {
 		return nil, err
 	}
 
-	if err := initBuckets(db); err != nil {
+	if err := migrate(db, cfg.DBPath); err != nil {
 		_ = db.Close()
 		return nil, err
 	}
@@ -38,21 +46,6 @@ func Open(cfg config.Config) (*bbolt.DB, error) {
 	return db, nil
 }
 
-func initBuckets(db *bbolt.DB) error {
-	return db.Update(func(tx *bbolt.Tx) error {
-		if _, err := tx.CreateBucketIfNotExists([]byte(bucketMeta)); err != nil {
-			return err
-		}
-		if _, err := tx.CreateBucketIfNotExists([]byte(bucketGames)); err != nil {
-			return err
-		}
-		if _, err := tx.CreateBucketIfNotExists([]byte(bucketBackups)); err != nil {
-			return err
-		}
-		return nil
-	})
-}
-
 func ensureDir(dbPath string) error {
 	dir := filepath.Dir(dbPath)
 	if dir == "." || dir == "" {
@@ -0,0 +1,372 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+const keySchemaVersion = "schema_version"
+
+type migration struct {
+	version     int
+	description string
+	up          func(tx *bbolt.Tx) error
+}
+
+// migrations are applied in order; each one bumps the schema version stored in
+// the meta bucket. Append new entries, never reorder or edit released ones.
+var migrations = []migration{
+	{1, "create meta, games and backups buckets", func(tx *bbolt.Tx) error {
+		return createBuckets(tx, bucketMeta, bucketGames, bucketBackups)
+	}},
+	{2, "create peers and sync_states buckets", func(tx *bbolt.Tx) error {
+		return createBuckets(tx, bucketPeers, bucketSyncStates)
+	}},
+	{3, "build per-game backup index", migrateBackupIndexV3},
+	{4, "create trash bucket", func(tx *bbolt.Tx) error {
+		return createBuckets(tx, bucketTrash)
+	}},
+	{5, "create audit bucket", func(tx *bbolt.Tx) error {
+		return createBuckets(tx, bucketAudit)
+	}},
+	{6, "create game_history bucket and record current game configurations", migrateGameHistoryV6},
+	{7, "re-key the backup index so times before 1970 sort first", migrateBackupIndexV7},
+	{8, "record per-game backup size totals in the backup index", migrateBackupSizesV8},
+}
+
+// errDryRun rolls back the dry-run transaction.
+var errDryRun = errors.New("dry run")
+
+// LatestVersion is the schema version this build migrates databases to.
+func LatestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate applies pending migrations one transaction at a time. When the
+// database already holds data, it is copied to a snapshot file first.
+func migrate(db *bbolt.DB, dbPath string) error {
+	current, fresh, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if current > LatestVersion() {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", current, LatestVersion())
+	}
+	if current == LatestVersion() {
+		return nil
+	}
+
+	if !fresh {
+		snapshot := fmt.Sprintf("%s.v%d-%s.bak", dbPath, current, time.Now().Format("20060102_150405"))
+		if err := db.View(func(tx *bbolt.Tx) error {
+			return tx.CopyFile(snapshot, 0o600)
+		}); err != nil {
+			return fmt.Errorf("snapshot before migration failed: %w", err)
+		}
+		log.Printf("db snapshot written to %s", snapshot)
+	}
+
+	for _, m := range pending(current) {
+		if err := db.Update(func(tx *bbolt.Tx) error {
+			return apply(tx, m)
+		}); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+		}
+		log.Printf("db migrated to version %d: %s", m.version, m.description)
+	}
+	return nil
+}
+
+// DryRunMigrations opens the database and runs all pending migrations in a
+// single transaction that is rolled back. It returns the migrations that
+// would be applied.
+func DryRunMigrations(dbPath string) ([]string, error) {
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		var out []string
+		for _, m := range migrations {
+			out = append(out, fmt.Sprintf("%d: %s", m.version, m.description))
+		}
+		return out, nil
+	}
+
+	db, err := bbolt.Open(dbPath, 0o600, &bbolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = db.Close() }()
+
+	current, _, err := schemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if current > LatestVersion() {
+		return nil, fmt.Errorf("database schema version %d is newer than supported version %d", current, LatestVersion())
+	}
+
+	var out []string
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, m := range pending(current) {
+			if err := apply(tx, m); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+			}
+			out = append(out, fmt.Sprintf("%d: %s", m.version, m.description))
+		}
+		return errDryRun
+	})
+	if !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return out, nil
+}
+
+func pending(current int) []migration {
+	var out []migration
+	for _, m := range migrations {
+		if m.version > current {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func apply(tx *bbolt.Tx, m migration) error {
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	meta, err := tx.CreateBucketIfNotExists([]byte(bucketMeta))
+	if err != nil {
+		return err
+	}
+	return meta.Put([]byte(keySchemaVersion), []byte(strconv.Itoa(m.version)))
+}
+
+// schemaVersion reads the stored version. Databases created before versioning
+// report 0; fresh reports whether the database has no buckets at all.
+func schemaVersion(db *bbolt.DB) (version int, fresh bool, err error) {
+	err = db.View(func(tx *bbolt.Tx) error {
+		fresh = true
+		if err := tx.ForEach(func(name []byte, _ *bbolt.Bucket) error {
+			fresh = false
+			return nil
+		}); err != nil {
+			return err
+		}
+		meta := tx.Bucket([]byte(bucketMeta))
+		if meta == nil {
+			return nil
+		}
+		v := meta.Get([]byte(keySchemaVersion))
+		if v == nil {
+			return nil
+		}
+		n, err := strconv.Atoi(string(v))
+		if err != nil {
+			return fmt.Errorf("invalid schema version %q", v)
+		}
+		version = n
+		return nil
+	})
+	return version, fresh, err
+}
+
+// v3Backup holds the backup fields the version 3 index is built from.
+type v3Backup struct {
+	ID        int64     `json:"id"`
+	GameID    int64     `json:"game_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// migrateBackupIndexV3 builds the backup index as laid out at version 3: one
+// nested bucket per game keyed by created_at (unix nano) and backup id, with
+// empty values.
+func migrateBackupIndexV3(tx *bbolt.Tx) error {
+	if tx.Bucket([]byte(bucketBackupIndex)) != nil {
+		if err := tx.DeleteBucket([]byte(bucketBackupIndex)); err != nil {
+			return err
+		}
+	}
+	root, err := tx.CreateBucket([]byte(bucketBackupIndex))
+	if err != nil {
+		return err
+	}
+	backups := tx.Bucket([]byte(bucketBackups))
+	if backups == nil {
+		return bbolt.ErrBucketNotFound
+	}
+	return backups.ForEach(func(k, v []byte) error {
+		var b v3Backup
+		if err := json.Unmarshal(v, &b); err != nil {
+			return err
+		}
+		idx, err := root.CreateBucketIfNotExists(binary.BigEndian.AppendUint64(nil, uint64(b.GameID)))
+		if err != nil {
+			return err
+		}
+		key := binary.BigEndian.AppendUint64(nil, uint64(b.CreatedAt.UnixNano()))
+		key = binary.BigEndian.AppendUint64(key, uint64(b.ID))
+		return idx.Put(key, []byte{})
+	})
+}
+
+// v6GameConfig is the game configuration as versioned at version 6.
+type v6GameConfig struct {
+	Name         string   `json:"name"`
+	GamePath     string   `json:"game_path"`
+	BackupRoot   string   `json:"backup_root"`
+	SteamAppID   int64    `json:"steam_app_id,omitempty"`
+	SteamUserID  string   `json:"steam_user_id,omitempty"`
+	Type         string   `json:"type,omitempty"`
+	RomName      string   `json:"rom_name,omitempty"`
+	SavePatterns []string `json:"save_patterns,omitempty"`
+	Platform     string   `json:"platform,omitempty"`
+	Category     string   `json:"category,omitempty"`
+	Favorite     bool     `json:"favorite,omitempty"`
+}
+
+// v6Game is a stored game; its configuration fields share the game's JSON
+// names.
+type v6Game struct {
+	ID        int64     `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	v6GameConfig
+}
+
+type v6GameVersion struct {
+	GameID  int64        `json:"game_id"`
+	Version int64        `json:"version"`
+	Time    time.Time    `json:"time"`
+	Reason  string       `json:"reason"`
+	Config  v6GameConfig `json:"config"`
+	Changes []struct{}   `json:"changes"`
+}
+
+// migrateGameHistoryV6 creates the game history and records the current
+// configuration of every game without history as its version 1, so later
+// changes have something to diff against. History keys are the game id and
+// the version, both big endian.
+func migrateGameHistoryV6(tx *bbolt.Tx) error {
+	if err := createBuckets(tx, bucketGameHistory); err != nil {
+		return err
+	}
+	games := tx.Bucket([]byte(bucketGames))
+	if games == nil {
+		return bbolt.ErrBucketNotFound
+	}
+	history := tx.Bucket([]byte(bucketGameHistory))
+	return games.ForEach(func(k, v []byte) error {
+		var g v6Game
+		if err := json.Unmarshal(v, &g); err != nil {
+			return err
+		}
+		prefix := binary.BigEndian.AppendUint64(nil, uint64(g.ID))
+		if hk, _ := history.Cursor().Seek(prefix); hk != nil && bytes.HasPrefix(hk, prefix) {
+			return nil
+		}
+		data, err := json.Marshal(&v6GameVersion{
+			GameID:  g.ID,
+			Version: 1,
+			Time:    g.UpdatedAt,
+			Reason:  "initial",
+			Config:  g.v6GameConfig,
+			Changes: []struct{}{},
+		})
+		if err != nil {
+			return err
+		}
+		return history.Put(binary.BigEndian.AppendUint64(prefix, 1), data)
+	})
+}
+
+// migrateBackupIndexV7 rebuilds the backup index with the version 7 keys:
+// created_at as unix nanoseconds with the sign bit flipped, clamped to the
+// int64 range, then the backup id.
+func migrateBackupIndexV7(tx *bbolt.Tx) error {
+	if err := tx.DeleteBucket([]byte(bucketBackupIndex)); err != nil && !errors.Is(err, bbolt.ErrBucketNotFound) {
+		return err
+	}
+	root, err := tx.CreateBucket([]byte(bucketBackupIndex))
+	if err != nil {
+		return err
+	}
+	backups := tx.Bucket([]byte(bucketBackups))
+	if backups == nil {
+		return bbolt.ErrBucketNotFound
+	}
+	minTime, maxTime := time.Unix(0, math.MinInt64), time.Unix(0, math.MaxInt64)
+	return backups.ForEach(func(k, v []byte) error {
+		var b v3Backup
+		if err := json.Unmarshal(v, &b); err != nil {
+			return err
+		}
+		idx, err := root.CreateBucketIfNotExists(binary.BigEndian.AppendUint64(nil, uint64(b.GameID)))
+		if err != nil {
+			return err
+		}
+		n := b.CreatedAt.UnixNano()
+		switch {
+		case b.CreatedAt.Before(minTime):
+			n = math.MinInt64
+		case b.CreatedAt.After(maxTime):
+			n = math.MaxInt64
+		}
+		key := binary.BigEndian.AppendUint64(nil, uint64(n)^1<<63)
+		key = binary.BigEndian.AppendUint64(key, uint64(b.ID))
+		return idx.Put(key, []byte{})
+	})
+}
+
+// v8Backup holds the backup fields the version 8 size totals are summed from.
+type v8Backup struct {
+	GameID    int64 `json:"game_id"`
+	SizeBytes int64 `json:"size_bytes"`
+}
+
+// migrateBackupSizesV8 stores each game's total backup size in the root of
+// the backup index, keyed by the game id followed by "size".
+func migrateBackupSizesV8(tx *bbolt.Tx) error {
+	root := tx.Bucket([]byte(bucketBackupIndex))
+	backups := tx.Bucket([]byte(bucketBackups))
+	if root == nil || backups == nil {
+		return bbolt.ErrBucketNotFound
+	}
+	totals := make(map[int64]int64)
+	if err := backups.ForEach(func(k, v []byte) error {
+		var b v8Backup
+		if err := json.Unmarshal(v, &b); err != nil {
+			return err
+		}
+		totals[b.GameID] += b.SizeBytes
+		return nil
+	}); err != nil {
+		return err
+	}
+	for gameID, total := range totals {
+		if total <= 0 {
+			continue
+		}
+		key := append(binary.BigEndian.AppendUint64(nil, uint64(gameID)), "size"...)
+		if err := root.Put(key, binary.BigEndian.AppendUint64(nil, uint64(total))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createBuckets(tx *bbolt.Tx, names ...string) error {
+	for _, name := range names {
+		if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gamebk/internal/model"
+)
+
+const (
+	// actorHeader names who is acting. There are no user accounts, so the
+	// actor is whatever the client says; peers identify themselves as "peer".
+	actorHeader = "X-Gamebk-Actor"
+
+	auditKey      = "gamebk.audit"
+	auditErrorKey = "gamebk.audit_error"
+
+	defaultAuditLimit = 100
+	// auditExportPage is how many entries an export reads per transaction.
+	auditExportPage = 500
+)
+
+// auditActions names the operations by method and route. Routes missing here
+// are recorded as "<method> <route>".
+var auditActions = map[string]string{
+	"POST /api/v1/games":                              "game.create",
+	"PATCH /api/v1/games/:id":                         "game.update",
+	"DELETE /api/v1/games/:id":                        "game.delete",
+	"POST /api/v1/games/:id/history/:version/revert":  "game.revert",
+	"PUT /api/v1/games/:id/icon":                      "game.icon.update",
+	"DELETE /api/v1/games/:id/icon":                   "game.icon.delete",
+	"POST /api/v1/games/:id/backup":                   "backup.create",
+	"POST /api/v1/games/:id/restore/latest":           "backup.restore",
+	"POST /api/v1/games/:id/restore/:backupId":        "backup.restore",
+	"PATCH /api/v1/games/:id/backups/:backupId":       "backup.update",
+	"DELETE /api/v1/games/:id/backups/:backupId":      "backup.delete",
+	"DELETE /api/v1/games/:id/backups":                "backup.delete_all",
+	"POST /api/v1/games/:id/backups/upload":           "backup.upload",
+	"POST /api/v1/games/:id/backups/archive":          "backup.upload",
+	"POST /api/v1/games/:id/backups/import":           "backup.import",
+	"POST /api/v1/games/:id/profiles":                 "profile.create",
+	"DELETE /api/v1/games/:id/profiles/:profile":      "profile.delete",
+	"POST /api/v1/games/:id/profiles/:profile/switch": "profile.switch",
+	"POST /api/v1/trash/:trashId/restore":             "trash.restore",
+	"DELETE /api/v1/trash/:trashId":                   "trash.purge",
+	"POST /api/v1/import":                             "library.import",
+	"PUT /api/v1/manifest":                            "manifest.update",
+	"POST /api/v1/reconcile":                          "reconcile",
+	"POST /api/v1/peers":                              "peer.create",
+	"DELETE /api/v1/peers/:peerId":                    "peer.delete",
+	"POST /api/v1/peers/:peerId/sync":                 "peer.sync",
+}
+
+// Audit is middleware recording every request that may change state in the
+// audit log, after it has been handled. Reads are not recorded.
+func (h *Handler) Audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		start := time.Now()
+		route := c.Request.Method + " " + c.FullPath()
+		e := &model.AuditEntry{
+			Time:     start.UTC(),
+			Actor:    strings.TrimSpace(c.GetHeader(actorHeader)),
+			ClientIP: c.ClientIP(),
+			Action:   auditActions[route],
+			Method:   c.Request.Method,
+			Path:     c.Request.URL.Path,
+		}
+		if e.Action == "" {
+			e.Action = strings.ToLower(route)
+		}
+		e.GameID, _ = strconv.ParseInt(c.Param("id"), 10, 64)
+		e.BackupID, _ = strconv.ParseInt(c.Param("backupId"), 10, 64)
+		c.Set(auditKey, e)
+
+		c.Next()
+
+		e.Status = c.Writer.Status()
+		e.Outcome = model.AuditSuccess
+		if e.Status >= http.StatusBadRequest {
+			e.Outcome = model.AuditFailure
+		}
+		e.ErrorCode = c.GetString(auditErrorKey)
+		e.DurationMS = time.Since(start).Milliseconds()
+		// the request context is done once the client has its response
+		if err := h.Repo.Audit.Append(context.Background(), e); err != nil {
+			log.Printf("audit append failed: %v", err)
+		}
+	}
+}
+
+// auditEntry returns the entry being recorded for the request, or nil.
+func auditEntry(c *gin.Context) *model.AuditEntry {
+	v, ok := c.Get(auditKey)
+	if !ok {
+		return nil
+	}
+	e, _ := v.(*model.AuditEntry)
+	return e
+}
+
+// auditGame records the game an operation touched, for routes without :id.
+func auditGame(c *gin.Context, gameID int64) {
+	if e := auditEntry(c); e != nil {
+		e.GameID = gameID
+	}
+}
+
+// auditBackup records the backup an operation touched and its size.
+func auditBackup(c *gin.Context, b *model.Backup) {
+	if e := auditEntry(c); e != nil && b != nil {
+		e.GameID = b.GameID
+		e.BackupID = b.ID
+		e.Bytes = b.SizeBytes
+	}
+}
+
+// auditAction overrides the recorded action, e.g. for a dry run.
+func auditAction(c *gin.Context, action string) {
+	if e := auditEntry(c); e != nil {
+		e.Action = action
+	}
+}
+
+// auditFilter selects audit entries.
+type auditFilter struct {
+	Action   string
+	Actor    string
+	Outcome  string
+	GameID   int64
+	BackupID int64
+	From, To time.Time
+}
+
+// parseAuditFilter reads action, actor, outcome, game_id, backup_id, from and
+// to from the query string, responding 400 when a value is invalid.
+func parseAuditFilter(c *gin.Context) (auditFilter, bool) {
+	f := auditFilter{
+		Action:  c.Query("action"),
+		Actor:   c.Query("actor"),
+		Outcome: c.Query("outcome"),
+	}
+	if f.Outcome != "" && f.Outcome != model.AuditSuccess && f.Outcome != model.AuditFailure {
+		respondError(c, http.StatusBadRequest, "validation_error", "outcome must be success or failure", nil)
+		return f, false
+	}
+	for key, dst := range map[string]*int64{"game_id": &f.GameID, "backup_id": &f.BackupID} {
+		if v := c.Query(key); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n <= 0 {
+				respondError(c, http.StatusBadRequest, "validation_error", "invalid "+key, nil)
+				return f, false
+			}
+			*dst = n
+		}
+	}
+	var err error
+	if f.From, err = parseDateQuery(c.Query("from"), false); err != nil {
+		respondError(c, http.StatusBadRequest, "validation_error", "invalid from", err.Error())
+		return f, false
+	}
+	if f.To, err = parseDateQuery(c.Query("to"), true); err != nil {
+		respondError(c, http.StatusBadRequest, "validation_error", "invalid to", err.Error())
+		return f, false
+	}
+	return f, true
+}
+
+// match reports whether e passes the filter. Actions match by prefix, so
+// "backup" selects every backup operation.
+func (f auditFilter) match(e *model.AuditEntry) bool {
+	switch {
+	case f.Action != "" && e.Action != f.Action && !strings.HasPrefix(e.Action, f.Action+"."):
+		return false
+	case f.Actor != "" && e.Actor != f.Actor:
+		return false
+	case f.Outcome != "" && e.Outcome != f.Outcome:
+		return false
+	case f.GameID != 0 && e.GameID != f.GameID:
+		return false
+	case f.BackupID != 0 && e.BackupID != f.BackupID:
+		return false
+	case !f.From.IsZero() && e.Time.Before(f.From):
+		return false
+	case !f.To.IsZero() && !e.Time.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// ListAudit lists audit entries, newest first. The page's next_cursor is the
+// id to pass as cursor for older entries; its total counts the whole log,
+// ignoring the filters, so a page reads only the entries it walks past.
+func (h *Handler) ListAudit(c *gin.Context) {
+	f, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	limit := defaultAuditLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxListLimit {
+			respondError(c, http.StatusBadRequest, "validation_error", "limit must be between 1 and 1000", nil)
+			return
+		}
+		limit = n
+	}
+	var before int64
+	if v := c.Query("cursor"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			respondError(c, http.StatusBadRequest, "invalid_cursor", "invalid cursor", nil)
+			return
+		}
+		before = n
+	}
+
+	total, err := h.Repo.Audit.Count(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to read audit log", err.Error())
+		return
+	}
+	out := []model.AuditEntry{}
+	page := &Page{Total: total, Limit: limit}
+	err = h.Repo.Audit.WalkBefore(c.Request.Context(), before, func(e *model.AuditEntry) bool {
+		if !f.match(e) {
+			return true
+		}
+		if len(out) == limit {
+			page.NextCursor = strconv.FormatInt(out[len(out)-1].ID, 10)
+			return false
+		}
+		out = append(out, *e)
+		return true
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to read audit log", err.Error())
+		return
+	}
+	respondPage(c, out, page)
+}
+
+// ExportAudit streams the entries matching the filters as JSON lines, oldest
+// first.
+func (h *Handler) ExportAudit(c *gin.Context) {
+	f, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+	// each page is read in its own transaction, so a slow client does not
+	// hold one open for the whole export
+	var after int64
+	for {
+		entries, err := h.Repo.Audit.Page(c.Request.Context(), after, auditExportPage)
+		if err != nil {
+			// headers are already sent, so the client sees a truncated export
+			_ = c.Error(err)
+			return
+		}
+		for i := range entries {
+			after = entries[i].ID
+			if !f.match(&entries[i]) {
+				continue
+			}
+			if err := enc.Encode(&entries[i]); err != nil {
+				_ = c.Error(err)
+				return
+			}
+		}
+		if len(entries) < auditExportPage || c.Request.Context().Err() != nil {
+			return
+		}
+	}
+}
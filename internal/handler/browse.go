@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"gamebk/internal/backup"
+)
+
+// ListBackupFiles lists the files of a backup. path selects the directory to
+// list (default the backup root), recursive walks the whole subtree and
+// hash=false skips computing sha256 checksums.
+func (h *Handler) ListBackupFiles(c *gin.Context) {
+	gameID, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	recursive, err := queryBool(c, "recursive", false)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "validation_error", "invalid recursive", nil)
+		return
+	}
+	hash, err := queryBool(c, "hash", true)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "validation_error", "invalid hash", nil)
+		return
+	}
+	b, ok := h.loadBackup(c, gameID)
+	if !ok {
+		return
+	}
+
+	dir := c.Query("path")
+	entries, err := backup.ListDir(b.BackupPath, dir, recursive, hash)
+	if err != nil {
+		respondFileError(c, err)
+		return
+	}
+	respondOK(c, gin.H{
+		"backup_id": b.ID,
+		"path":      dir,
+		"entries":   entries,
+	})
+}
+
+// DownloadBackupFile streams a single file from a backup.
+func (h *Handler) DownloadBackupFile(c *gin.Context) {
+	gameID, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	rel := c.Query("path")
+	if rel == "" {
+		respondError(c, http.StatusBadRequest, "validation_error", "path is required", nil)
+		return
+	}
+	b, ok := h.loadBackup(c, gameID)
+	if !ok {
+		return
+	}
+
+	abs, err := backup.ResolvePath(b.BackupPath, rel)
+	if err != nil {
+		respondFileError(c, err)
+		return
+	}
+	info, err := os.Lstat(abs)
+	if err != nil {
+		respondFileError(c, err)
+		return
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		respondError(c, http.StatusBadRequest, "validation_error", "path is a symlink", nil)
+		return
+	}
+	if !info.Mode().IsRegular() {
+		respondError(c, http.StatusBadRequest, "validation_error", "path is not a file", nil)
+		return
+	}
+	// a linked directory on the way could still lead outside the backup
+	_, real, err := backup.ResolveReal(b.BackupPath, rel)
+	if err != nil {
+		respondFileError(c, err)
+		return
+	}
+	c.FileAttachment(real, info.Name())
+}
+
+func respondFileError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		respondError(c, http.StatusNotFound, "not_found", "path not found in backup", nil)
+	case errors.Is(err, backup.ErrNotDir):
+		respondError(c, http.StatusBadRequest, "validation_error", "path is not a directory", nil)
+	case errors.Is(err, backup.ErrUnsafePath):
+		respondError(c, http.StatusBadRequest, "validation_error", "invalid path", err.Error())
+	default:
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to read backup", err.Error())
+	}
+}
+
+// queryBool parses an optional boolean query parameter.
+func queryBool(c *gin.Context, key string, def bool) (bool, error) {
+	v := c.Query(key)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.ParseBool(v)
+}
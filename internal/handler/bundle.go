@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gamebk/internal/backup"
+	"gamebk/internal/job"
+	"gamebk/internal/model"
+)
+
+// DownloadBackup streams a backup as a zip or tar.gz bundle with an embedded
+// manifest holding the game and backup names, creation time and checksums.
+func (h *Handler) DownloadBackup(c *gin.Context) {
+	gameID, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", backup.FormatZip)
+	if format != backup.FormatZip && format != backup.FormatTarGz {
+		respondError(c, http.StatusBadRequest, "validation_error", "format must be zip or tar.gz", nil)
+		return
+	}
+
+	game, ok := h.loadGame(c, gameID)
+	if !ok {
+		return
+	}
+	b, ok := h.loadBackup(c, game.ID)
+	if !ok {
+		return
+	}
+	if _, err := os.Stat(b.BackupPath); err != nil {
+		respondError(c, http.StatusNotFound, "invalid_path", "backup files not found", err.Error())
+		return
+	}
+
+	contentType := "application/zip"
+	if format == backup.FormatTarGz {
+		contentType = "application/gzip"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(game.Name+"-"+b.Name, `"`, "")+"."+format+`"`)
+	c.Status(http.StatusOK)
+
+	m := &backup.BundleManifest{
+		GameName:   game.Name,
+		BackupName: b.Name,
+		Profile:    b.Profile,
+		Notes:      b.Notes,
+		Tags:       b.Tags,
+		Pinned:     b.Pinned,
+		CreatedAt:  b.CreatedAt,
+	}
+	if err := backup.WriteBundle(c.Writer, format, b.BackupPath, m); err != nil {
+		// headers are already sent, so the client sees a truncated archive
+		_ = c.Error(err)
+	}
+}
+
+// UploadBackup registers a bundle produced by DownloadBackup as a backup of the
+// game. The body is the raw bundle or a multipart form with a "file" field.
+// The backup keeps the manifest's name and creation time unless name is given.
+// The manifest's pinned flag is not trusted; pinned=true pins the backup.
+func (h *Handler) UploadBackup(c *gin.Context) {
+	id, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" && !validateBackupName(c, name) {
+		return
+	}
+	pinned, err := queryBool(c, "pinned", false)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "validation_error", "invalid pinned", nil)
+		return
+	}
+
+	game, ok := h.loadGame(c, id)
+	if !ok {
+		return
+	}
+	game, ok = h.resolveGame(c, game)
+	if !ok {
+		return
+	}
+	done, ok := h.startJob(c, game.ID, job.KindUpload)
+	if !ok {
+		return
+	}
+	defer done()
+
+	if err := os.MkdirAll(game.BackupRoot, 0o755); err != nil {
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to create backup root", err.Error())
+		return
+	}
+
+	body := io.Reader(c.Request.Body)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "bad_request", "file is required", err.Error())
+			return
+		}
+		f, err := fh.Open()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "bad_request", "failed to read file", err.Error())
+			return
+		}
+		defer func() { _ = f.Close() }()
+		body = f
+	}
+
+	// zip needs random access, so spool the upload next to the backups first
+	tmp, err := os.CreateTemp(game.BackupRoot, ".upload-*")
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to create temp file", err.Error())
+		return
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	_, err = io.Copy(tmp, body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "io_error", "failed to receive bundle", err.Error())
+		return
+	}
+
+	m, backupPath, err := backup.InstallBundle(tmp.Name(), game.BackupRoot, name)
+	if err != nil {
+		if errors.Is(err, backup.ErrInvalidBundle) {
+			respondError(c, http.StatusBadRequest, "invalid_bundle", "bundle rejected", err.Error())
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to install bundle", err.Error())
+		return
+	}
+
+	createdAt := m.CreatedAt.UTC()
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+	b := &model.Backup{
+		GameID:     game.ID,
+		Name:       m.BackupName,
+		BackupPath: backupPath,
+		Profile:    model.StoredProfile(m.Profile),
+		Notes:      m.Notes,
+		Tags:       m.Tags,
+		Pinned:     pinned,
+		SizeBytes:  m.SizeBytes,
+		CreatedAt:  createdAt,
+	}
+	if name != "" {
+		b.Name = name
+	}
+	if !h.adoptProfile(c, game, b) {
+		_ = os.RemoveAll(backupPath)
+		return
+	}
+	if err := h.Repo.Backups.Create(c.Request.Context(), b); err != nil {
+		_ = os.RemoveAll(backupPath)
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to save backup", err.Error())
+		return
+	}
+	if game.LastBackupAt == nil || createdAt.After(*game.LastBackupAt) {
+		if err := h.Repo.Games.UpdateLastBackupAt(c.Request.Context(), game.ID, createdAt); err != nil {
+			respondError(c, http.StatusInternalServerError, "db_error", "failed to update game", err.Error())
+			return
+		}
+	}
+
+	auditBackup(c, b)
+	respondCreated(c, b)
+}
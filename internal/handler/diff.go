@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"gamebk/internal/backup"
+	"gamebk/internal/library"
+	"gamebk/internal/repository"
+)
+
+// DiffBackup compares a backup with a base: the live game_path by default
+// (base=live), or another backup of the same game (base=<backup id>). The
+// result lists what applying the backup would change relative to the base, so
+// with the default base it is a restore preview.
+func (h *Handler) DiffBackup(c *gin.Context) {
+	gameID, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	game, ok := h.loadGame(c, gameID)
+	if !ok {
+		return
+	}
+	b, ok := h.loadBackup(c, game.ID)
+	if !ok {
+		return
+	}
+
+	baseName := c.DefaultQuery("base", "live")
+	basePath := ""
+	if baseName == "live" {
+		resolved, err := h.Paths.Resolve(game.GamePath)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid_path", err.Error(), nil)
+			return
+		}
+		basePath = resolved
+	} else {
+		baseID, err := strconv.ParseInt(baseName, 10, 64)
+		if err != nil || baseID <= 0 {
+			respondError(c, http.StatusBadRequest, "validation_error", "base must be live or a backup id", nil)
+			return
+		}
+		other, err := h.Repo.Backups.GetByID(c.Request.Context(), baseID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				respondError(c, http.StatusNotFound, "not_found", "base backup not found", nil)
+				return
+			}
+			respondError(c, http.StatusInternalServerError, "db_error", "failed to load backup", err.Error())
+			return
+		}
+		if other.GameID != game.ID {
+			respondError(c, http.StatusBadRequest, "bad_request", "base backup does not belong to game", nil)
+			return
+		}
+		if _, err := os.Stat(other.BackupPath); err != nil {
+			respondError(c, http.StatusNotFound, "invalid_path", "base backup files not found", err.Error())
+			return
+		}
+		basePath = other.BackupPath
+	}
+	if _, err := os.Stat(b.BackupPath); err != nil {
+		respondError(c, http.StatusNotFound, "invalid_path", "backup files not found", err.Error())
+		return
+	}
+
+	d, err := backup.DiffDirs(basePath, b.BackupPath, library.SaveScope(game))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to compare", err.Error())
+		return
+	}
+	respondOK(c, gin.H{
+		"backup_id": b.ID,
+		"base":      baseName,
+		"diff":      d,
+	})
+}
@@ -2,9 +2,11 @@ package handler
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -13,36 +15,202 @@ import (
 	"go.etcd.io/bbolt"
 
 	"gamebk/internal/backup"
+	"gamebk/internal/config"
+	"gamebk/internal/job"
+	"gamebk/internal/library"
+	"gamebk/internal/manifest"
 	"gamebk/internal/model"
+	"gamebk/internal/pathvar"
+	"gamebk/internal/peer"
 	"gamebk/internal/repository"
+	"gamebk/internal/trash"
 )
 
 type Handler struct {
-	DB   *bbolt.DB
-	Repo *repository.Repository
+	DB       *bbolt.DB
+	Repo     *repository.Repository
+	Jobs     *job.Tracker
+	Paths    *pathvar.Resolver
+	Manifest *manifest.Store
+	Syncer   *peer.Syncer
+	// IconDir holds the uploaded game icons, named after the game id.
+	IconDir string
+	Trash   *trash.Purger
 }
 
-func New(db *bbolt.DB) *Handler {
+func New(cfg config.Config, db *bbolt.DB) *Handler {
+	repo := repository.New(db)
+	jobs := job.NewTracker()
+	paths := &pathvar.Resolver{SteamRoot: cfg.SteamRoot}
 	return &Handler{
-		DB:   db,
-		Repo: repository.New(db),
+		DB:       db,
+		Repo:     repo,
+		Jobs:     jobs,
+		Paths:    paths,
+		Manifest: &manifest.Store{Path: cfg.ManifestPath},
+		Syncer:   &peer.Syncer{Repo: repo, Jobs: jobs, Paths: paths},
+		IconDir:  cfg.IconDir,
+		Trash:    &trash.Purger{Repo: repo, Jobs: jobs, Days: cfg.TrashDays},
 	}
 }
 
+// startJob marks a job as running for the game. It responds with 409 and
+// returns false when another job for the same game is still running.
+func (h *Handler) startJob(c *gin.Context, gameID int64, kind string) (func(), bool) {
+	done, current, ok := h.Jobs.Start(gameID, kind)
+	if !ok {
+		respondError(c, http.StatusConflict, "job_running", "another job is running for this game", current)
+		return nil, false
+	}
+	return done, true
+}
+
+// parseGameID reads the :id path parameter, responding 400 when invalid.
+func parseGameID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, "bad_request", "invalid game id", nil)
+		return 0, false
+	}
+	return id, true
+}
+
+// loadGame fetches a game, responding 404 or 500 on failure.
+func (h *Handler) loadGame(c *gin.Context, id int64) (*model.Game, bool) {
+	game, err := h.Repo.Games.GetByID(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "not_found", "game not found", nil)
+			return nil, false
+		}
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to load game", err.Error())
+		return nil, false
+	}
+	return game, true
+}
+
+// loadBackup fetches the backup named by the :backupId path parameter and
+// checks that it belongs to the game, responding with an error otherwise.
+func (h *Handler) loadBackup(c *gin.Context, gameID int64) (*model.Backup, bool) {
+	backupID, err := strconv.ParseInt(c.Param("backupId"), 10, 64)
+	if err != nil || backupID <= 0 {
+		respondError(c, http.StatusBadRequest, "bad_request", "invalid backup id", nil)
+		return nil, false
+	}
+	b, err := h.Repo.Backups.GetByID(c.Request.Context(), backupID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "not_found", "backup not found", nil)
+			return nil, false
+		}
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to load backup", err.Error())
+		return nil, false
+	}
+	if b.GameID != gameID {
+		respondError(c, http.StatusBadRequest, "bad_request", "backup does not belong to game", nil)
+		return nil, false
+	}
+	return b, true
+}
+
+// gameView is a game as returned by the API: the stored path templates plus
+// the paths they resolve to on this machine.
+type gameView struct {
+	*model.Game
+	ResolvedGamePath   string `json:"resolved_game_path"`
+	ResolvedBackupRoot string `json:"resolved_backup_root"`
+	PathError          string `json:"path_error,omitempty"`
+	IconURL            string `json:"icon_url,omitempty"`
+	// BackupSizeBytes is the total size of the game's backups; only game
+	// lists report it.
+	BackupSizeBytes *int64 `json:"backup_size_bytes,omitempty"`
+}
+
+func (h *Handler) viewGame(g *model.Game) gameView {
+	return viewGameWith(h.Paths, g)
+}
+
+// viewGameWith is viewGame resolving with paths, a cached resolver when many
+// games are viewed at once.
+func viewGameWith(paths *pathvar.Resolver, g *model.Game) gameView {
+	v := gameView{Game: g}
+	if g.Icon != "" {
+		v.IconURL = fmt.Sprintf("/api/v1/games/%d/icon", g.ID)
+	}
+	resolved, err := paths.ResolveGame(g)
+	if err != nil {
+		v.PathError = err.Error()
+		return v
+	}
+	v.ResolvedGamePath = resolved.GamePath
+	v.ResolvedBackupRoot = resolved.BackupRoot
+	return v
+}
+
+// CreateGame 创建游戏。指定 steam_app_id 时 name 和 game_path 可省略，
+// 分别取 Steam 应用名称和已知的存档位置
 func (h *Handler) CreateGame(c *gin.Context) {
 	var req struct {
-		Name       string `json:"name" binding:"required"`
-		GamePath   string `json:"game_path" binding:"required"`
+		Name       string `json:"name" binding:"required_without=SteamAppID"`
+		GamePath   string `json:"game_path"`
 		BackupRoot string `json:"backup_root" binding:"required"`
+		SteamAppID int64  `json:"steam_app_id" binding:"gte=0"`
+		// SteamUserID pins the account <storeUserId> stands for; the one
+		// that played last is pinned when omitted.
+		SteamUserID string `json:"steam_user_id"`
+		// Type, RomName and SavePatterns describe an emulator game.
+		Type         string   `json:"type"`
+		RomName      string   `json:"rom_name"`
+		SavePatterns []string `json:"save_patterns"`
+		Platform     string   `json:"platform"`
+		Category     string   `json:"category"`
+		Favorite     bool     `json:"favorite"`
 	}
 	if !bindAndValidate(c, &req) {
 		return
 	}
 
 	game := &model.Game{
-		Name:       req.Name,
-		GamePath:   req.GamePath,
-		BackupRoot: req.BackupRoot,
+		Name:         req.Name,
+		GamePath:     strings.TrimSpace(req.GamePath),
+		BackupRoot:   strings.TrimSpace(req.BackupRoot),
+		SteamAppID:   req.SteamAppID,
+		SteamUserID:  strings.TrimSpace(req.SteamUserID),
+		Type:         req.Type,
+		RomName:      strings.TrimSpace(req.RomName),
+		SavePatterns: req.SavePatterns,
+		Platform:     strings.TrimSpace(req.Platform),
+		Category:     strings.TrimSpace(req.Category),
+		Favorite:     req.Favorite,
+	}
+	if !validateGame(c, game) {
+		return
+	}
+	if game.SteamAppID != 0 {
+		app, ok := h.loadSteamApp(c, game.SteamAppID)
+		if !ok {
+			return
+		}
+		if game.Name == "" {
+			game.Name = app.Name
+		}
+		if game.GamePath == "" {
+			game.GamePath = h.steamGamePath(app)
+		}
+		if !pinSteamUser(c, game, app) {
+			return
+		}
+	} else if game.SteamUserID != "" {
+		respondError(c, http.StatusBadRequest, "validation_error", "steam_user_id requires steam_app_id", nil)
+		return
+	}
+	if game.GamePath == "" {
+		respondError(c, http.StatusBadRequest, "validation_error", "game_path is required", nil)
+		return
+	}
+	resolved, ok := h.resolveGame(c, game)
+	if !ok || !h.checkScopeOverlap(c, resolved) {
+		return
 	}
 
 	if err := h.Repo.Games.Create(c.Request.Context(), game); err != nil {
@@ -50,7 +218,8 @@ func (h *Handler) CreateGame(c *gin.Context) {
 		return
 	}
 
-	respondCreated(c, game)
+	auditGame(c, game.ID)
+	respondCreated(c, h.viewGame(game))
 }
 
 func (h *Handler) UpdateGame(c *gin.Context) {
@@ -61,15 +230,25 @@ func (h *Handler) UpdateGame(c *gin.Context) {
 	}
 
 	var req struct {
-		Name       *string `json:"name"`
-		GamePath   *string `json:"game_path"`
-		BackupRoot *string `json:"backup_root"`
+		Name         *string   `json:"name"`
+		GamePath     *string   `json:"game_path"`
+		BackupRoot   *string   `json:"backup_root"`
+		SteamAppID   *int64    `json:"steam_app_id"`
+		SteamUserID  *string   `json:"steam_user_id"`
+		Type         *string   `json:"type"`
+		RomName      *string   `json:"rom_name"`
+		SavePatterns *[]string `json:"save_patterns"`
+		Platform     *string   `json:"platform"`
+		Category     *string   `json:"category"`
+		Favorite     *bool     `json:"favorite"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		respondError(c, http.StatusBadRequest, "bad_request", err.Error(), nil)
 		return
 	}
-	if req.Name == nil && req.GamePath == nil && req.BackupRoot == nil {
+	if req.Name == nil && req.GamePath == nil && req.BackupRoot == nil && req.SteamAppID == nil && req.SteamUserID == nil &&
+		req.Type == nil && req.RomName == nil && req.SavePatterns == nil &&
+		req.Platform == nil && req.Category == nil && req.Favorite == nil {
 		respondError(c, http.StatusBadRequest, "validation_error", "no fields to update", nil)
 		return
 	}
@@ -85,10 +264,18 @@ func (h *Handler) UpdateGame(c *gin.Context) {
 	}
 
 	game := &model.Game{
-		ID:         id,
-		Name:       existing.Name,
-		GamePath:   existing.GamePath,
-		BackupRoot: existing.BackupRoot,
+		ID:           id,
+		Name:         existing.Name,
+		GamePath:     existing.GamePath,
+		BackupRoot:   existing.BackupRoot,
+		SteamAppID:   existing.SteamAppID,
+		SteamUserID:  existing.SteamUserID,
+		Type:         existing.Type,
+		RomName:      existing.RomName,
+		SavePatterns: existing.SavePatterns,
+		Platform:     existing.Platform,
+		Category:     existing.Category,
+		Favorite:     existing.Favorite,
 	}
 	if req.Name != nil {
 		name := strings.TrimSpace(*req.Name)
@@ -114,6 +301,60 @@ func (h *Handler) UpdateGame(c *gin.Context) {
 		}
 		game.BackupRoot = backupRoot
 	}
+	if req.SteamAppID != nil {
+		// 0 unlinks the game from Steam
+		if *req.SteamAppID < 0 {
+			respondError(c, http.StatusBadRequest, "validation_error", "invalid steam_app_id", nil)
+			return
+		}
+		if *req.SteamAppID != game.SteamAppID {
+			game.SteamUserID = ""
+		}
+		game.SteamAppID = *req.SteamAppID
+	}
+	if req.SteamUserID != nil {
+		game.SteamUserID = strings.TrimSpace(*req.SteamUserID)
+		if !validateSteamUser(c, game.SteamUserID) {
+			return
+		}
+	}
+	if game.SteamAppID == 0 && game.SteamUserID != "" {
+		respondError(c, http.StatusBadRequest, "validation_error", "steam_user_id requires steam_app_id", nil)
+		return
+	}
+	if req.SteamAppID != nil && *req.SteamAppID != 0 {
+		app, ok := h.loadSteamApp(c, *req.SteamAppID)
+		if !ok || !pinSteamUser(c, game, app) {
+			return
+		}
+	}
+	if req.Type != nil {
+		game.Type = *req.Type
+	}
+	if req.RomName != nil {
+		game.RomName = strings.TrimSpace(*req.RomName)
+	}
+	if req.SavePatterns != nil {
+		game.SavePatterns = *req.SavePatterns
+	}
+	if req.Platform != nil {
+		game.Platform = strings.TrimSpace(*req.Platform)
+	}
+	if req.Category != nil {
+		game.Category = strings.TrimSpace(*req.Category)
+	}
+	if req.Favorite != nil {
+		game.Favorite = *req.Favorite
+	}
+	if !validateGame(c, game) {
+		return
+	}
+	if req.GamePath != nil || req.BackupRoot != nil || req.SteamAppID != nil || req.SteamUserID != nil || game.Type == model.GameTypeEmulator {
+		resolved, ok := h.resolveGame(c, game)
+		if !ok || !h.checkScopeOverlap(c, resolved) {
+			return
+		}
+	}
 
 	if err := h.Repo.Games.Update(c.Request.Context(), game); err != nil {
 		respondError(c, http.StatusInternalServerError, "db_error", "failed to update game", err.Error())
@@ -125,7 +366,102 @@ func (h *Handler) UpdateGame(c *gin.Context) {
 		respondError(c, http.StatusInternalServerError, "db_error", "failed to load game", err.Error())
 		return
 	}
-	respondOK(c, updated)
+	respondOK(c, h.viewGame(updated))
+}
+
+// DeleteGame 删除游戏及其所有备份记录，remove_files=true 时同时把备份文件移入回收站。
+// 有置顶备份时拒绝删除，除非 force=true
+func (h *Handler) DeleteGame(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, "bad_request", "invalid game id", nil)
+		return
+	}
+	removeFiles, err := queryBool(c, "remove_files", false)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "validation_error", "invalid remove_files", nil)
+		return
+	}
+	force, err := queryBool(c, "force", false)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "validation_error", "invalid force", nil)
+		return
+	}
+
+	game, err := h.Repo.Games.GetByID(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "not_found", "game not found", nil)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to load game", err.Error())
+		return
+	}
+
+	done, ok := h.startJob(c, game.ID, job.KindDelete)
+	if !ok {
+		return
+	}
+	defer done()
+
+	backups, err := h.Repo.Backups.ListByGameID(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to list backups", err.Error())
+		return
+	}
+
+	// pinned records go with the game even when their files stay
+	if !force {
+		for i := range backups {
+			if backups[i].Pinned {
+				respondError(c, http.StatusConflict, "backup_pinned", "the game has pinned backups; unpin them or set force", gin.H{"backup_id": backups[i].ID, "name": backups[i].Name})
+				return
+			}
+		}
+	}
+	// each folder goes to the trash together with its record, so a failure
+	// part way leaves the game with exactly the backups not yet moved
+	trashIDs := []int64{}
+	if removeFiles {
+		resolved, ok := h.resolveGame(c, game)
+		if !ok {
+			return
+		}
+		for i := range backups {
+			item, err := h.trashBackup(c.Request.Context(), resolved, &backups[i])
+			if err != nil {
+				respondError(c, http.StatusInternalServerError, "io_error", "failed to move backup to trash; the game and its remaining backups are kept", gin.H{
+					"backup_id": backups[i].ID,
+					"trash_ids": trashIDs,
+					"error":     err.Error(),
+				})
+				return
+			}
+			trashIDs = append(trashIDs, item.ID)
+		}
+	}
+
+	if err := h.Repo.Games.Delete(c.Request.Context(), id); err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to delete game", gin.H{
+			"trash_ids": trashIDs,
+			"error":     err.Error(),
+		})
+		return
+	}
+	if game.Icon != "" {
+		_ = h.removeIcon(game.Icon)
+	}
+
+	out := gin.H{
+		"game_id":         id,
+		"game_name":       game.Name,
+		"deleted_backups": len(backups),
+		"files_removed":   removeFiles,
+	}
+	if removeFiles {
+		out["trash_ids"] = trashIDs
+	}
+	respondOK(c, out)
 }
 
 func (h *Handler) BackupGame(c *gin.Context) {
@@ -146,6 +482,17 @@ func (h *Handler) BackupGame(c *gin.Context) {
 		return
 	}
 
+	name := ""
+	if namePtr != nil {
+		name = strings.TrimSpace(*namePtr)
+	}
+	if name == "" {
+		name = time.Now().Format("20060102_150405")
+	}
+	if !validateBackupName(c, name) {
+		return
+	}
+
 	game, err := h.Repo.Games.GetByID(c.Request.Context(), id)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
@@ -155,6 +502,16 @@ func (h *Handler) BackupGame(c *gin.Context) {
 		respondError(c, http.StatusInternalServerError, "db_error", "failed to load game", err.Error())
 		return
 	}
+	game, ok = h.resolveGame(c, game)
+	if !ok {
+		return
+	}
+
+	done, ok := h.startJob(c, game.ID, job.KindBackup)
+	if !ok {
+		return
+	}
+	defer done()
 
 	if _, err := os.Stat(game.GamePath); err != nil {
 		respondError(c, http.StatusBadRequest, "invalid_path", "game_path not found", err.Error())
@@ -165,17 +522,18 @@ func (h *Handler) BackupGame(c *gin.Context) {
 		return
 	}
 
-	name := ""
-	if namePtr != nil {
-		name = strings.TrimSpace(*namePtr)
-	}
-	if name == "" {
-		name = time.Now().Format("20060102_150405")
-	}
 	backupPath := filepath.Join(game.BackupRoot, name)
+	if _, err := os.Stat(backupPath); err == nil {
+		respondError(c, http.StatusConflict, "backup_exists", "a backup with this name already exists", backupPath)
+		return
+	}
 
-	size, err := backup.CopyDir(game.GamePath, backupPath)
+	size, err := backupGameFiles(game, backupPath)
 	if err != nil {
+		if errors.Is(err, backup.ErrNoMatch) {
+			respondError(c, http.StatusBadRequest, "no_saves", "no save files match the game's save patterns", err.Error())
+			return
+		}
 		respondError(c, http.StatusInternalServerError, "io_error", "backup failed", err.Error())
 		return
 	}
@@ -184,6 +542,7 @@ func (h *Handler) BackupGame(c *gin.Context) {
 		GameID:     game.ID,
 		Name:       name,
 		BackupPath: backupPath,
+		Profile:    game.Profile,
 		SizeBytes:  size,
 	}
 	if err := h.Repo.Backups.Create(c.Request.Context(), b); err != nil {
@@ -195,6 +554,7 @@ func (h *Handler) BackupGame(c *gin.Context) {
 		return
 	}
 
+	auditBackup(c, b)
 	respondCreated(c, b)
 }
 
@@ -204,6 +564,10 @@ func (h *Handler) RestoreLatest(c *gin.Context) {
 		respondError(c, http.StatusBadRequest, "bad_request", "invalid game id", nil)
 		return
 	}
+	req, ok := bindRestoreRequest(c)
+	if !ok {
+		return
+	}
 
 	game, err := h.Repo.Games.GetByID(c.Request.Context(), id)
 	if err != nil {
@@ -215,7 +579,7 @@ func (h *Handler) RestoreLatest(c *gin.Context) {
 		return
 	}
 
-	b, err := h.Repo.Backups.GetLatestByGameID(c.Request.Context(), game.ID)
+	b, err := h.Repo.Backups.GetLatestByProfile(c.Request.Context(), game.ID, game.ActiveProfile())
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			respondError(c, http.StatusNotFound, "not_found", "backup not found", nil)
@@ -224,13 +588,38 @@ func (h *Handler) RestoreLatest(c *gin.Context) {
 		respondError(c, http.StatusInternalServerError, "db_error", "failed to load backup", err.Error())
 		return
 	}
+	// failed restores are recorded against the backup too
+	auditBackup(c, b)
 
-	if err := restoreBackupToGame(b.BackupPath, game.GamePath); err != nil {
-		respondError(c, http.StatusInternalServerError, "io_error", "restore failed", err.Error())
+	game, ok = h.resolveGame(c, game)
+	if !ok {
+		return
+	}
+	target, ok := h.restoreTarget(c, game, req)
+	if !ok {
+		return
+	}
+	if req.DryRun {
+		h.respondRestorePlan(c, game, b, target, req.Paths)
 		return
 	}
 
-	respondOK(c, b)
+	done, ok := h.startJob(c, game.ID, job.KindRestore)
+	if !ok {
+		return
+	}
+	defer done()
+
+	restored, err := restoreBackupToGame(b.BackupPath, target, req.Paths, library.SaveScope(game))
+	if err != nil {
+		respondRestoreError(c, err)
+		return
+	}
+
+	if target == game.GamePath {
+		target = ""
+	}
+	respondRestored(c, b, target, restored)
 }
 
 func (h *Handler) RestoreByID(c *gin.Context) {
@@ -244,6 +633,10 @@ func (h *Handler) RestoreByID(c *gin.Context) {
 		respondError(c, http.StatusBadRequest, "bad_request", "invalid backup id", nil)
 		return
 	}
+	req, ok := bindRestoreRequest(c)
+	if !ok {
+		return
+	}
 
 	game, err := h.Repo.Games.GetByID(c.Request.Context(), gameID)
 	if err != nil {
@@ -268,49 +661,298 @@ func (h *Handler) RestoreByID(c *gin.Context) {
 		respondError(c, http.StatusBadRequest, "bad_request", "backup does not belong to game", nil)
 		return
 	}
+	auditBackup(c, b)
+
+	game, ok = h.resolveGame(c, game)
+	if !ok {
+		return
+	}
+	target, ok := h.restoreTarget(c, game, req)
+	if !ok {
+		return
+	}
+	// another profile's save in the live directory would be backed up into
+	// the active profile next
+	if target == game.GamePath && b.ProfileName() != game.ActiveProfile() {
+		respondError(c, http.StatusConflict, "profile_mismatch", "the backup belongs to another profile; switch to it or restore to a target_path", gin.H{
+			"backup_profile": b.ProfileName(),
+			"active_profile": game.ActiveProfile(),
+		})
+		return
+	}
+	if req.DryRun {
+		h.respondRestorePlan(c, game, b, target, req.Paths)
+		return
+	}
+
+	done, ok := h.startJob(c, game.ID, job.KindRestore)
+	if !ok {
+		return
+	}
+	defer done()
 
-	if err := restoreBackupToGame(b.BackupPath, game.GamePath); err != nil {
-		respondError(c, http.StatusInternalServerError, "io_error", "restore failed", err.Error())
+	restored, err := restoreBackupToGame(b.BackupPath, target, req.Paths, library.SaveScope(game))
+	if err != nil {
+		respondRestoreError(c, err)
 		return
 	}
 
-	respondOK(c, b)
+	if target == game.GamePath {
+		target = ""
+	}
+	respondRestored(c, b, target, restored)
 }
 
-func restoreBackupToGame(backupPath, gamePath string) error {
+// backupGameFiles copies the game's saves to backupPath: the whole save
+// directory, or only the files matching an emulator game's patterns.
+func backupGameFiles(game *model.Game, backupPath string) (int64, error) {
+	scope := library.SaveScope(game)
+	if scope == nil {
+		return backup.CopyDir(game.GamePath, backupPath)
+	}
+	rels, err := scope.Match(game.GamePath)
+	if err != nil {
+		return 0, err
+	}
+	return backup.CopySelected(game.GamePath, backupPath, rels)
+}
+
+// restoreBackupToGame replaces the live save (or another target directory)
+// with the backup. A full restore swaps in a complete copy, so gamePath is left
+// untouched if copying fails. When paths is set, only the matching entries are
+// restored and the rest of gamePath is kept. With scope set (an emulator game)
+// a full restore only replaces the files within scope. Returns the restored
+// relative paths, or nil for a full restore of a whole directory.
+func restoreBackupToGame(backupPath, gamePath string, paths []string, scope *backup.Scope) ([]string, error) {
 	info, err := os.Stat(backupPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if !info.IsDir() {
-		return errors.New("backup path is not a directory")
+		return nil, errors.New("backup path is not a directory")
 	}
 
 	if err := os.MkdirAll(gamePath, 0o755); err != nil {
-		return err
+		return nil, err
 	}
-	if err := backup.ClearDir(gamePath); err != nil {
-		return err
+	if len(paths) > 0 {
+		rels, err := backup.MatchPaths(backupPath, paths)
+		if err != nil {
+			return nil, err
+		}
+		_, err = backup.RestorePaths(backupPath, gamePath, rels)
+		return rels, err
+	}
+	if scope != nil {
+		return backup.RestoreSelected(backupPath, gamePath, scope)
 	}
-	_, err = backup.CopyDirInto(backupPath, gamePath)
-	return err
+	_, err = backup.ReplaceDir(backupPath, gamePath)
+	return nil, err
+}
+
+type restoreRequest struct {
+	// Paths limits the restore to matching relative paths or globs.
+	Paths []string `json:"paths"`
+	// DryRun runs the checks and reports the planned changes only.
+	DryRun bool `json:"dry_run"`
+	// TargetPath restores into another directory instead of game_path.
+	TargetPath string `json:"target_path"`
+	// Overwrite allows restoring into a target_path that is not empty.
+	Overwrite bool `json:"overwrite"`
 }
 
+// restoreTarget returns the directory a restore writes to. target_path must
+// be missing or empty unless overwrite is set, and must not overlap the save
+// directory or backup root of any game. The game's paths must already be
+// resolved.
+func (h *Handler) restoreTarget(c *gin.Context, game *model.Game, req restoreRequest) (string, bool) {
+	if strings.TrimSpace(req.TargetPath) == "" {
+		return game.GamePath, true
+	}
+	target, err := h.Paths.Resolve(req.TargetPath)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_path", err.Error(), nil)
+		return "", false
+	}
+	if !filepath.IsAbs(target) {
+		respondError(c, http.StatusBadRequest, "validation_error", "target_path must be absolute", nil)
+		return "", false
+	}
+	target = filepath.Clean(target)
+	if target == filepath.Clean(game.GamePath) {
+		return game.GamePath, true
+	}
+	// check the directory a link points to as well as the link itself
+	targets := []string{target}
+	if real, err := filepath.EvalSymlinks(target); err == nil && real != target {
+		targets = append(targets, real)
+	}
+
+	games, err := h.Repo.Games.List(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to list games", err.Error())
+		return "", false
+	}
+	paths := h.Paths.Cached()
+	for i := range games {
+		g := &games[i]
+		if g.ID == game.ID {
+			g = game
+		} else if resolved, err := paths.ResolveGame(g); err == nil {
+			g = resolved
+		}
+		for _, t := range targets {
+			if overlaps(t, g.GamePath) {
+				respondError(c, http.StatusBadRequest, "path_overlap", "target_path overlaps the save directory of a game", gin.H{"game_id": g.ID, "game_path": g.GamePath})
+				return "", false
+			}
+			if overlaps(t, g.BackupRoot) {
+				respondError(c, http.StatusBadRequest, "path_overlap", "target_path overlaps the backup root of a game", gin.H{"game_id": g.ID, "backup_root": g.BackupRoot})
+				return "", false
+			}
+		}
+	}
+
+	if !req.Overwrite {
+		entries, err := os.ReadDir(target)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			respondError(c, http.StatusBadRequest, "invalid_path", err.Error(), nil)
+			return "", false
+		}
+		if len(entries) > 0 {
+			respondError(c, http.StatusConflict, "target_not_empty", "target_path is not empty; set overwrite to replace its contents", target)
+			return "", false
+		}
+	}
+	return target, true
+}
+
+// overlaps reports whether one of the paths contains the other.
+func overlaps(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	return backup.Within(a, b) || backup.Within(b, a)
+}
+
+// bindRestoreRequest reads the optional restore body.
+func bindRestoreRequest(c *gin.Context) (restoreRequest, bool) {
+	var req restoreRequest
+	if c.Request.ContentLength == 0 {
+		return req, true
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "bad_request", err.Error(), nil)
+		return req, false
+	}
+	return req, true
+}
+
+// noGameProcessCheck is the detail of a passing no_gamebk_job check: gamebk
+// cannot tell whether the game itself is running.
+const noGameProcessCheck = "no gamebk job is running for this game; whether the game itself is running is not detected, close it before restoring"
+
+// respondRestorePlan answers a dry-run restore with the checks and the files
+// that would be deleted, overwritten and created. The no_gamebk_job check
+// only covers gamebk's own jobs, not a running game.
+func (h *Handler) respondRestorePlan(c *gin.Context, game *model.Game, b *model.Backup, target string, paths []string) {
+	auditAction(c, "backup.restore.dry_run")
+	auditBackup(c, b)
+	plan, err := backup.PlanRestore(b.BackupPath, target, paths, library.SaveScope(game))
+	if err != nil {
+		respondRestoreError(c, err)
+		return
+	}
+	check := backup.Check{Name: "no_gamebk_job", OK: true, Detail: noGameProcessCheck}
+	if j, busy := h.Jobs.Running(game.ID); busy {
+		check.OK = false
+		check.Detail = j.Kind + " job is running for this game"
+		plan.OK = false
+	}
+	plan.Checks = append(plan.Checks, check)
+	respondOK(c, gin.H{"dry_run": true, "backup": b, "target_path": target, "plan": plan})
+}
+
+// respondRestored responds with the restored backup, plus the target for a
+// restore outside game_path and the restored paths for a partial restore.
+func respondRestored(c *gin.Context, b *model.Backup, target string, restored []string) {
+	auditBackup(c, b)
+	if restored == nil && target == "" {
+		respondOK(c, b)
+		return
+	}
+	out := gin.H{"backup": b}
+	if target != "" {
+		out["target_path"] = target
+	}
+	if restored != nil {
+		out["restored_paths"] = restored
+	}
+	respondOK(c, out)
+}
+
+// respondRestoreError maps restore failures to responses.
+func respondRestoreError(c *gin.Context, err error) {
+	if errors.Is(err, backup.ErrNoMatch) || errors.Is(err, backup.ErrUnsafePath) {
+		respondError(c, http.StatusBadRequest, "validation_error", "invalid restore paths", err.Error())
+		return
+	}
+	respondError(c, http.StatusInternalServerError, "io_error", "restore failed", err.Error())
+}
+
+// ListGames lists the games, newest first unless sort and order say
+// otherwise. q searches the name, from and to bound the last backup time, and
+// platform, category and favorite filter the metadata. With limit set the
+// list is paged; the response's page carries the cursor of the next page.
 func (h *Handler) ListGames(c *gin.Context) {
+	q, ok := parseListQuery(c, gameSorts)
+	if !ok {
+		return
+	}
+	f, ok := parseGameFilter(c)
+	if !ok {
+		return
+	}
 	games, err := h.Repo.Games.List(c.Request.Context())
 	if err != nil {
 		respondError(c, http.StatusInternalServerError, "db_error", "failed to list games", err.Error())
 		return
 	}
-	respondOK(c, games)
+	sizes, err := h.Repo.Backups.SizeByGame(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to list backups", err.Error())
+		return
+	}
+	games = slices.DeleteFunc(games, func(g model.Game) bool {
+		return !f.match(&g) || !q.matchName(g.Name) || !q.inRange(g.LastBackupAt)
+	})
+	games, page := paginate(games, func(g *model.Game) sortKey { return gameSortKey(g, sizes, q.Sort) }, q)
+
+	out := make([]gameView, 0, len(games))
+	paths := h.Paths.Cached()
+	for i := range games {
+		v := viewGameWith(paths, &games[i])
+		size := sizes[games[i].ID]
+		v.BackupSizeBytes = &size
+		out = append(out, v)
+	}
+	respondPage(c, out, page)
 }
 
+// ListBackups lists the backups of a game, newest first unless sort and order
+// say otherwise. q searches the name, from and to bound the creation time, and
+// profile, tag and pinned filter the list. With limit set the list is paged
+// like ListGames.
 func (h *Handler) ListBackups(c *gin.Context) {
 	gameID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil || gameID <= 0 {
 		respondError(c, http.StatusBadRequest, "bad_request", "invalid game id", nil)
 		return
 	}
+	q, ok := parseListQuery(c, backupSorts)
+	if !ok {
+		return
+	}
 
 	_, err = h.Repo.Games.GetByID(c.Request.Context(), gameID)
 	if err != nil {
@@ -327,10 +969,31 @@ func (h *Handler) ListBackups(c *gin.Context) {
 		respondError(c, http.StatusInternalServerError, "db_error", "failed to list backups", err.Error())
 		return
 	}
+	// ?profile= 只返回该存档配置的备份
+	if profile := c.Query("profile"); profile != "" {
+		backups = slices.DeleteFunc(backups, func(b model.Backup) bool { return b.ProfileName() != profile })
+	}
+	// ?tag= 和 ?pinned= 按标签和置顶过滤
+	if tag := c.Query("tag"); tag != "" {
+		backups = slices.DeleteFunc(backups, func(b model.Backup) bool { return !b.HasTag(tag) })
+	}
+	if c.Query("pinned") != "" {
+		pinned, err := queryBool(c, "pinned", false)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "validation_error", "invalid pinned", nil)
+			return
+		}
+		backups = slices.DeleteFunc(backups, func(b model.Backup) bool { return b.Pinned != pinned })
+	}
+	backups = slices.DeleteFunc(backups, func(b model.Backup) bool {
+		return !q.matchName(b.Name) || !q.inRange(&b.CreatedAt)
+	})
+	backups, page := paginate(backups, func(b *model.Backup) sortKey { return backupSortKey(b, q.Sort) }, q)
 
-	respondOK(c, backups)
+	respondPage(c, backups, page)
 }
 
+// DeleteBackup 将备份移入回收站，置顶的备份不能删除
 func (h *Handler) DeleteBackup(c *gin.Context) {
 	gameID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil || gameID <= 0 {
@@ -343,7 +1006,7 @@ func (h *Handler) DeleteBackup(c *gin.Context) {
 		return
 	}
 
-	_, err = h.Repo.Games.GetByID(c.Request.Context(), gameID)
+	game, err := h.Repo.Games.GetByID(c.Request.Context(), gameID)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			respondError(c, http.StatusNotFound, "not_found", "game not found", nil)
@@ -366,20 +1029,32 @@ func (h *Handler) DeleteBackup(c *gin.Context) {
 		respondError(c, http.StatusBadRequest, "bad_request", "backup does not belong to game", nil)
 		return
 	}
+	if b.Pinned {
+		respondPinned(c, b)
+		return
+	}
+	game, ok := h.resolveGame(c, game)
+	if !ok {
+		return
+	}
 
-	if err := os.RemoveAll(b.BackupPath); err != nil {
-		respondError(c, http.StatusInternalServerError, "io_error", "failed to delete backup files", err.Error())
+	done, ok := h.startJob(c, gameID, job.KindDelete)
+	if !ok {
 		return
 	}
-	if err := h.Repo.Backups.DeleteByID(c.Request.Context(), backupID); err != nil {
-		respondError(c, http.StatusInternalServerError, "db_error", "failed to delete backup", err.Error())
+	defer done()
+
+	item, err := h.trashBackup(c.Request.Context(), game, b)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to move backup to trash", err.Error())
 		return
 	}
 
-	respondOK(c, gin.H{"deleted": backupID})
+	auditBackup(c, b)
+	respondOK(c, gin.H{"deleted": backupID, "trash_id": item.ID})
 }
 
-// DeleteAllBackups 删除某个游戏的所有备份
+// DeleteAllBackups 删除某个游戏的所有未置顶备份
 func (h *Handler) DeleteAllBackups(c *gin.Context) {
 	gameID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil || gameID <= 0 {
@@ -398,6 +1073,17 @@ func (h *Handler) DeleteAllBackups(c *gin.Context) {
 		return
 	}
 
+	game, ok := h.resolveGame(c, game)
+	if !ok {
+		return
+	}
+
+	done, ok := h.startJob(c, gameID, job.KindDelete)
+	if !ok {
+		return
+	}
+	defer done()
+
 	// 获取该游戏的所有备份
 	backups, err := h.Repo.Backups.ListByGameID(c.Request.Context(), gameID)
 	if err != nil {
@@ -405,22 +1091,25 @@ func (h *Handler) DeleteAllBackups(c *gin.Context) {
 		return
 	}
 
-	// 删除所有备份文件
+	// 置顶的备份保留，其余的逐个移入回收站
+	deleted := 0
+	var lastBackupAt time.Time
 	for _, b := range backups {
-		if err := os.RemoveAll(b.BackupPath); err != nil {
-			respondError(c, http.StatusInternalServerError, "io_error", "failed to delete backup files", err.Error())
+		if b.Pinned {
+			if lastBackupAt.IsZero() {
+				lastBackupAt = b.CreatedAt
+			}
+			continue
+		}
+		if _, err := h.trashBackup(c.Request.Context(), game, &b); err != nil {
+			respondError(c, http.StatusInternalServerError, "io_error", "failed to move backup to trash", err.Error())
 			return
 		}
+		deleted++
 	}
 
-	// 从数据库中删除所有备份记录
-	if err := h.Repo.Backups.DeleteAllByGameID(c.Request.Context(), gameID); err != nil {
-		respondError(c, http.StatusInternalServerError, "db_error", "failed to delete backups", err.Error())
-		return
-	}
-
-	// 重置游戏的最后备份时间
-	if err := h.Repo.Games.UpdateLastBackupAt(c.Request.Context(), gameID, time.Time{}); err != nil {
+	// 最后备份时间改为最新的置顶备份，没有则重置
+	if err := h.Repo.Games.UpdateLastBackupAt(c.Request.Context(), gameID, lastBackupAt); err != nil {
 		respondError(c, http.StatusInternalServerError, "db_error", "failed to update game", err.Error())
 		return
 	}
@@ -428,7 +1117,8 @@ func (h *Handler) DeleteAllBackups(c *gin.Context) {
 	respondOK(c, gin.H{
 		"game_id":         gameID,
 		"game_name":       game.Name,
-		"deleted_backups": len(backups),
-		"message":         "all backups deleted successfully",
+		"deleted_backups": deleted,
+		"pinned_kept":     len(backups) - deleted,
+		"message":         "all unpinned backups moved to trash",
 	})
 }
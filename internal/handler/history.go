@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"gamebk/internal/model"
+	"gamebk/internal/repository"
+)
+
+// versionView is a configuration version with what reverting to it would
+// change in the current configuration.
+type versionView struct {
+	*model.GameVersion
+	RevertChanges []model.GameChange `json:"revert_changes"`
+}
+
+// GameHistory lists the configuration versions of a game, newest first. Each
+// version carries its diff from the previous one.
+func (h *Handler) GameHistory(c *gin.Context) {
+	gameID, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	if _, ok := h.loadGame(c, gameID); !ok {
+		return
+	}
+	versions, err := h.Repo.Games.History(c.Request.Context(), gameID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to load game history", err.Error())
+		return
+	}
+	if versions == nil {
+		versions = []model.GameVersion{}
+	}
+	respondOK(c, versions)
+}
+
+// GetGameVersion returns one configuration version and the changes a revert
+// to it would make.
+func (h *Handler) GetGameVersion(c *gin.Context) {
+	gameID, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	game, ok := h.loadGame(c, gameID)
+	if !ok {
+		return
+	}
+	ver, ok := h.loadVersion(c, gameID)
+	if !ok {
+		return
+	}
+	respondOK(c, versionView{GameVersion: ver, RevertChanges: revertChanges(game, ver)})
+}
+
+// RevertGame puts back the configuration of a prior version. The reverted
+// configuration is validated like an update and recorded as a new version.
+func (h *Handler) RevertGame(c *gin.Context) {
+	gameID, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	game, ok := h.loadGame(c, gameID)
+	if !ok {
+		return
+	}
+	ver, ok := h.loadVersion(c, gameID)
+	if !ok {
+		return
+	}
+	changes := revertChanges(game, ver)
+	if len(changes) == 0 {
+		respondOK(c, h.viewGame(game))
+		return
+	}
+
+	reverted := *game
+	reverted.ApplyConfig(ver.Config)
+	if !validateGame(c, &reverted) {
+		return
+	}
+	if reverted.SteamAppID != 0 && reverted.SteamAppID != game.SteamAppID {
+		if _, ok := h.loadSteamApp(c, reverted.SteamAppID); !ok {
+			return
+		}
+	}
+	if reverted.GamePath != game.GamePath || reverted.BackupRoot != game.BackupRoot || reverted.SteamAppID != game.SteamAppID ||
+		reverted.Type == model.GameTypeEmulator {
+		resolved, ok := h.resolveGame(c, &reverted)
+		if !ok || !h.checkScopeOverlap(c, resolved) {
+			return
+		}
+	}
+
+	if err := h.Repo.Games.Revert(c.Request.Context(), gameID, ver.Version); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "not_found", "game version not found", nil)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to revert game", err.Error())
+		return
+	}
+	updated, err := h.Repo.Games.GetByID(c.Request.Context(), gameID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to load game", err.Error())
+		return
+	}
+	respondOK(c, h.viewGame(updated))
+}
+
+func (h *Handler) loadVersion(c *gin.Context, gameID int64) (*model.GameVersion, bool) {
+	version, err := strconv.ParseInt(c.Param("version"), 10, 64)
+	if err != nil || version <= 0 {
+		respondError(c, http.StatusBadRequest, "bad_request", "invalid version", nil)
+		return nil, false
+	}
+	ver, err := h.Repo.Games.GetVersion(c.Request.Context(), gameID, version)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "not_found", "game version not found", nil)
+			return nil, false
+		}
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to load game version", err.Error())
+		return nil, false
+	}
+	return ver, true
+}
+
+// revertChanges lists what reverting game to ver would change.
+func revertChanges(game *model.Game, ver *model.GameVersion) []model.GameChange {
+	changes := model.DiffGameConfig(game.Config(), ver.Config)
+	if changes == nil {
+		changes = []model.GameChange{}
+	}
+	return changes
+}
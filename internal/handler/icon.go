@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxIconSize bounds uploaded icons; covers larger than this are not icons.
+const maxIconSize = 2 << 20
+
+// iconExts maps the accepted image types, as sniffed from the content, to the
+// extension the icon is stored with.
+var iconExts = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// UploadIcon stores the request body, raw or as the multipart field "file",
+// as the game's icon. The image type is detected from the content.
+func (h *Handler) UploadIcon(c *gin.Context) {
+	id, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	game, ok := h.loadGame(c, id)
+	if !ok {
+		return
+	}
+
+	body := io.Reader(c.Request.Body)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "bad_request", "file is required", err.Error())
+			return
+		}
+		f, err := fh.Open()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "bad_request", "failed to read file", err.Error())
+			return
+		}
+		defer func() { _ = f.Close() }()
+		body = f
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxIconSize+1))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "bad_request", "failed to read icon", err.Error())
+		return
+	}
+	if len(data) > maxIconSize {
+		respondError(c, http.StatusRequestEntityTooLarge, "icon_too_large", "icon must be at most 2 MiB", nil)
+		return
+	}
+	ext, ok := iconExts[http.DetectContentType(data)]
+	if !ok {
+		respondError(c, http.StatusBadRequest, "invalid_icon", "icon must be a png, jpeg, gif or webp image", nil)
+		return
+	}
+
+	if err := os.MkdirAll(h.IconDir, 0o755); err != nil {
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to create icon directory", err.Error())
+		return
+	}
+	// write beside the target and rename, so a failed upload keeps the old icon
+	tmp, err := os.CreateTemp(h.IconDir, ".icon-*")
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to create temp file", err.Error())
+		return
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := io.Copy(tmp, bytes.NewReader(data)); err != nil {
+		_ = tmp.Close()
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to write icon", err.Error())
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to write icon", err.Error())
+		return
+	}
+	name := strconv.FormatInt(game.ID, 10) + ext
+	if err := os.Rename(tmp.Name(), filepath.Join(h.IconDir, name)); err != nil {
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to write icon", err.Error())
+		return
+	}
+
+	if err := h.Repo.Games.UpdateIcon(c.Request.Context(), game.ID, name); err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to update game", err.Error())
+		return
+	}
+	if game.Icon != "" && game.Icon != name {
+		_ = h.removeIcon(game.Icon)
+	}
+	game.Icon = name
+	respondOK(c, h.viewGame(game))
+}
+
+// GetIcon serves the game's icon.
+func (h *Handler) GetIcon(c *gin.Context) {
+	id, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	game, ok := h.loadGame(c, id)
+	if !ok {
+		return
+	}
+	if game.Icon == "" {
+		respondError(c, http.StatusNotFound, "not_found", "game has no icon", nil)
+		return
+	}
+	p := filepath.Join(h.IconDir, filepath.Base(game.Icon))
+	if _, err := os.Stat(p); err != nil {
+		respondError(c, http.StatusNotFound, "not_found", "icon file not found", err.Error())
+		return
+	}
+	c.File(p)
+}
+
+// DeleteIcon removes the game's icon.
+func (h *Handler) DeleteIcon(c *gin.Context) {
+	id, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	game, ok := h.loadGame(c, id)
+	if !ok {
+		return
+	}
+	if game.Icon == "" {
+		respondError(c, http.StatusNotFound, "not_found", "game has no icon", nil)
+		return
+	}
+	if err := h.Repo.Games.UpdateIcon(c.Request.Context(), game.ID, ""); err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to update game", err.Error())
+		return
+	}
+	if err := h.removeIcon(game.Icon); err != nil {
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to delete icon file", err.Error())
+		return
+	}
+	respondOK(c, gin.H{"deleted": game.Icon})
+}
+
+// removeIcon deletes an icon file; a file that is already gone is not an
+// error.
+func (h *Handler) removeIcon(name string) error {
+	err := os.Remove(filepath.Join(h.IconDir, filepath.Base(name)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gamebk/internal/backup"
+	"gamebk/internal/job"
+	"gamebk/internal/model"
+)
+
+// Import modes for ImportBackups.
+const (
+	importInPlace = "in_place"
+	importCopy    = "copy"
+	importMove    = "move"
+)
+
+type importSkip struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// ImportBackups registers backup folders made by hand or by other tools.
+// source_path is a directory whose sub-folders are backups (or, with single,
+// one backup folder) or a .zip/.tar.gz archive of the same layout. It may not
+// overlap game_path, nor backup_root unless imported in place.
+func (h *Handler) ImportBackups(c *gin.Context) {
+	id, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		SourcePath string `json:"source_path" binding:"required"`
+		Mode       string `json:"mode"`
+		Single     bool   `json:"single"`
+	}
+	if !bindAndValidate(c, &req) {
+		return
+	}
+	src := filepath.Clean(strings.TrimSpace(req.SourcePath))
+	isArchive := backup.IsArchive(src)
+	mode := req.Mode
+	switch {
+	case mode == "" && isArchive:
+		mode = importCopy
+	case mode == "":
+		mode = importInPlace
+	case mode != importInPlace && mode != importCopy && mode != importMove:
+		respondError(c, http.StatusBadRequest, "validation_error", "mode must be in_place, copy or move", nil)
+		return
+	case mode == importInPlace && isArchive:
+		respondError(c, http.StatusBadRequest, "validation_error", "archives cannot be imported in place", nil)
+		return
+	}
+
+	game, ok := h.loadGame(c, id)
+	if !ok {
+		return
+	}
+	game, ok = h.resolveGame(c, game)
+	if !ok {
+		return
+	}
+	// the live save must never become a backup, and a copy or move must not
+	// read from the folder it writes to
+	if overlaps(src, game.GamePath) {
+		respondError(c, http.StatusBadRequest, "validation_error", "source_path must not be, contain or lie inside game_path", nil)
+		return
+	}
+	if mode != importInPlace && !isArchive && overlaps(src, game.BackupRoot) {
+		respondError(c, http.StatusBadRequest, "validation_error", "source_path must not be, contain or lie inside backup_root unless imported in place", nil)
+		return
+	}
+	done, ok := h.startJob(c, game.ID, job.KindImport)
+	if !ok {
+		return
+	}
+	defer done()
+
+	info, err := os.Stat(src)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_path", "source_path not found", err.Error())
+		return
+	}
+	if mode != importInPlace {
+		if err := os.MkdirAll(game.BackupRoot, 0o755); err != nil {
+			respondError(c, http.StatusInternalServerError, "io_error", "failed to create backup root", err.Error())
+			return
+		}
+	}
+
+	baseName := filepath.Base(src)
+	if isArchive {
+		// staged beside the backup root rather than in it, so nothing
+		// half-extracted shows up there and the final moves stay on one volume
+		tmp, err := os.MkdirTemp(filepath.Dir(game.BackupRoot), ".gamebk-import-")
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "io_error", "failed to create temp dir", err.Error())
+			return
+		}
+		defer func() { _ = os.RemoveAll(tmp) }()
+		extracted := filepath.Join(tmp, "src")
+		if _, err := backup.ExtractArchive(src, extracted); err != nil {
+			respondError(c, http.StatusBadRequest, "io_error", "failed to extract archive", err.Error())
+			return
+		}
+		for _, ext := range []string{".tar.gz", ".tgz", ".zip"} {
+			if strings.HasSuffix(strings.ToLower(baseName), ext) {
+				baseName = baseName[:len(baseName)-len(ext)]
+				break
+			}
+		}
+		src = extracted
+	} else if !info.IsDir() {
+		respondError(c, http.StatusBadRequest, "invalid_path", "source_path is not a directory or supported archive", nil)
+		return
+	}
+
+	type candidate struct{ name, path string }
+	var candidates []candidate
+	if req.Single {
+		candidates = append(candidates, candidate{name: baseName, path: src})
+	} else {
+		dirs, err := backup.SubDirs(src)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "io_error", "failed to read source_path", err.Error())
+			return
+		}
+		for _, d := range dirs {
+			candidates = append(candidates, candidate{name: d.Name(), path: filepath.Join(src, d.Name())})
+		}
+	}
+	if len(candidates) == 0 {
+		respondError(c, http.StatusBadRequest, "validation_error", "no backup folders found in source_path", nil)
+		return
+	}
+
+	existing, err := h.Repo.Backups.ListByGameID(c.Request.Context(), game.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to list backups", err.Error())
+		return
+	}
+	names := make(map[string]bool, len(existing))
+	for _, b := range existing {
+		names[b.Name] = true
+	}
+
+	imported := []model.Backup{}
+	skipped := []importSkip{}
+	var latest time.Time
+	for _, cand := range candidates {
+		if err := backup.ValidateName(cand.name); err != nil {
+			skipped = append(skipped, importSkip{Name: cand.name, Path: cand.path, Reason: err.Error()})
+			continue
+		}
+		if names[cand.name] {
+			skipped = append(skipped, importSkip{Name: cand.name, Path: cand.path, Reason: "backup name already exists"})
+			continue
+		}
+		info, err := os.Stat(cand.path)
+		if err != nil {
+			skipped = append(skipped, importSkip{Name: cand.name, Path: cand.path, Reason: err.Error()})
+			continue
+		}
+		createdAt, ok := backup.TimeFromName(cand.name)
+		if !ok {
+			createdAt = info.ModTime()
+		}
+
+		backupPath := filepath.Join(game.BackupRoot, cand.name)
+		var size int64
+		switch {
+		case mode == importInPlace:
+			backupPath = cand.path
+			size, err = backup.DirSize(cand.path)
+		case mode == importMove || isArchive:
+			// extracted archive files are temporary, so moving them is the cheapest copy
+			size, err = backup.MoveDir(cand.path, backupPath)
+		default:
+			size, err = backup.CopyDir(cand.path, backupPath)
+		}
+		if err != nil {
+			skipped = append(skipped, importSkip{Name: cand.name, Path: cand.path, Reason: err.Error()})
+			continue
+		}
+
+		b := &model.Backup{
+			GameID:     game.ID,
+			Name:       cand.name,
+			BackupPath: backupPath,
+			Profile:    game.Profile,
+			SizeBytes:  size,
+			CreatedAt:  createdAt.UTC(),
+		}
+		if err := h.Repo.Backups.Create(c.Request.Context(), b); err != nil {
+			respondError(c, http.StatusInternalServerError, "db_error", "failed to save backup", err.Error())
+			return
+		}
+		names[cand.name] = true
+		imported = append(imported, *b)
+		if b.CreatedAt.After(latest) {
+			latest = b.CreatedAt
+		}
+	}
+
+	if len(imported) > 0 && (game.LastBackupAt == nil || latest.After(*game.LastBackupAt)) {
+		if err := h.Repo.Games.UpdateLastBackupAt(c.Request.Context(), game.ID, latest); err != nil {
+			respondError(c, http.StatusInternalServerError, "db_error", "failed to update game", err.Error())
+			return
+		}
+	}
+
+	respondCreated(c, gin.H{
+		"game_id":  game.ID,
+		"mode":     mode,
+		"imported": imported,
+		"skipped":  skipped,
+	})
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-yaml"
+
+	"gamebk/internal/library"
+)
+
+// ExportLibrary returns every game as a versioned JSON or YAML document.
+// The document is returned as-is rather than in the response envelope so it
+// can be saved and imported directly.
+func (h *Handler) ExportLibrary(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "yaml" {
+		respondError(c, http.StatusBadRequest, "validation_error", "format must be json or yaml", nil)
+		return
+	}
+	includeBackups := false
+	if v := c.Query("include_backups"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "validation_error", "invalid include_backups", nil)
+			return
+		}
+		includeBackups = b
+	}
+
+	doc, err := library.Export(c.Request.Context(), h.Repo, includeBackups)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to export library", err.Error())
+		return
+	}
+
+	filename := "gamebk-library." + format
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "yaml" {
+		data, err := yaml.Marshal(doc)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "encode_error", "failed to encode yaml", err.Error())
+			return
+		}
+		c.Data(http.StatusOK, "application/yaml", data)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, doc)
+}
+
+// ImportLibrary applies an exported document, JSON or YAML according to the
+// format query parameter or the Content-Type header. mode=replace also deletes
+// the games the document leaves out; their backup folders stay on disk.
+func (h *Handler) ImportLibrary(c *gin.Context) {
+	mode := c.DefaultQuery("mode", library.ModeMerge)
+	if mode != library.ModeMerge && mode != library.ModeReplace {
+		respondError(c, http.StatusBadRequest, "validation_error", "mode must be merge or replace", nil)
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "bad_request", err.Error(), nil)
+		return
+	}
+	format := c.Query("format")
+	if format == "" {
+		format = "json"
+		if strings.Contains(c.ContentType(), "yaml") {
+			format = "yaml"
+		}
+	}
+
+	var doc library.Document
+	switch format {
+	case "json":
+		err = json.Unmarshal(body, &doc)
+	case "yaml":
+		err = yaml.Unmarshal(body, &doc)
+	default:
+		respondError(c, http.StatusBadRequest, "validation_error", "format must be json or yaml", nil)
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "bad_request", "invalid document", err.Error())
+		return
+	}
+
+	// icons of games a replace deletes are removed afterwards
+	icons := make(map[int64]string)
+	if mode == library.ModeReplace {
+		games, err := h.Repo.Games.List(c.Request.Context())
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "db_error", "failed to list games", err.Error())
+			return
+		}
+		for _, g := range games {
+			icons[g.ID] = g.Icon
+		}
+	}
+
+	result, err := library.Import(c.Request.Context(), h.Repo, h.Jobs, h.Paths, &doc, mode)
+	if err != nil {
+		if errors.Is(err, library.ErrUnsupportedVersion) {
+			respondError(c, http.StatusBadRequest, "unsupported_version", err.Error(), nil)
+			return
+		}
+		if errors.Is(err, library.ErrInvalidDocument) {
+			respondError(c, http.StatusBadRequest, "validation_error", err.Error(), nil)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to import library", err.Error())
+		return
+	}
+	for _, r := range result {
+		if r.Action == library.ActionDeleted && icons[r.GameID] != "" {
+			_ = h.removeIcon(icons[r.GameID])
+		}
+	}
+	respondOK(c, result)
+}
@@ -0,0 +1,304 @@
+package handler
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gamebk/internal/model"
+)
+
+// Sort keys of the list endpoints.
+const (
+	sortCreated    = "created"
+	sortName       = "name"
+	sortLastBackup = "last_backup"
+	sortSize       = "size"
+)
+
+// maxListLimit bounds the page size of the list endpoints.
+const maxListLimit = 1000
+
+// gameSorts and backupSorts map the sort keys of each list to whether they
+// sort descending by default: names ascending, everything else newest or
+// largest first.
+var (
+	gameSorts   = map[string]bool{sortCreated: true, sortName: false, sortLastBackup: true, sortSize: true}
+	backupSorts = map[string]bool{sortCreated: true, sortName: false, sortSize: true}
+)
+
+// listQuery holds the options shared by the list endpoints: name search, a
+// date range, sort order and the page to return.
+type listQuery struct {
+	Search string
+	// From and To bound the list's date, To exclusive; zero means open.
+	From, To time.Time
+	Sort     string
+	Desc     bool
+	// Limit is the page size; 0 returns every item.
+	Limit int
+	// After is the sort key of the last item of the previous page.
+	After *sortKey
+}
+
+// sortKey orders one list item: by Str, then Num, then ID. Only the field of
+// the chosen sort is set, so ties fall back to the id.
+type sortKey struct {
+	Str string `json:"s,omitempty"`
+	Num int64  `json:"n,omitempty"`
+	ID  int64  `json:"id"`
+}
+
+func (k sortKey) compare(o sortKey) int {
+	if n := strings.Compare(k.Str, o.Str); n != 0 {
+		return n
+	}
+	if n := cmp.Compare(k.Num, o.Num); n != 0 {
+		return n
+	}
+	return cmp.Compare(k.ID, o.ID)
+}
+
+// cursor is the opaque next_cursor value. It carries the sort it was made
+// for, so it cannot be replayed against another order.
+type cursor struct {
+	Sort string  `json:"sort"`
+	Desc bool    `json:"desc"`
+	Key  sortKey `json:"key"`
+}
+
+func encodeCursor(q listQuery, k sortKey) string {
+	data, _ := json.Marshal(cursor{Sort: q.Sort, Desc: q.Desc, Key: k})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseListQuery reads q, from, to, sort, order, limit and cursor from the
+// query string, responding 400 when a value is invalid. sorts lists the
+// accepted sort keys; the default is sortCreated.
+func parseListQuery(c *gin.Context, sorts map[string]bool) (listQuery, bool) {
+	q := listQuery{
+		Search: strings.TrimSpace(c.Query("q")),
+		Sort:   c.DefaultQuery("sort", sortCreated),
+	}
+	desc, ok := sorts[q.Sort]
+	if !ok {
+		keys := make([]string, 0, len(sorts))
+		for k := range sorts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		respondError(c, http.StatusBadRequest, "validation_error", "sort must be one of "+strings.Join(keys, ", "), nil)
+		return q, false
+	}
+	q.Desc = desc
+	switch c.Query("order") {
+	case "":
+	case "asc":
+		q.Desc = false
+	case "desc":
+		q.Desc = true
+	default:
+		respondError(c, http.StatusBadRequest, "validation_error", "order must be asc or desc", nil)
+		return q, false
+	}
+
+	var err error
+	if q.From, err = parseDateQuery(c.Query("from"), false); err != nil {
+		respondError(c, http.StatusBadRequest, "validation_error", "invalid from", err.Error())
+		return q, false
+	}
+	if q.To, err = parseDateQuery(c.Query("to"), true); err != nil {
+		respondError(c, http.StatusBadRequest, "validation_error", "invalid to", err.Error())
+		return q, false
+	}
+
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxListLimit {
+			respondError(c, http.StatusBadRequest, "validation_error", "limit must be between 1 and 1000", nil)
+			return q, false
+		}
+		q.Limit = n
+	}
+	if v := c.Query("cursor"); v != "" {
+		var cur cursor
+		data, err := base64.RawURLEncoding.DecodeString(v)
+		if err == nil {
+			err = json.Unmarshal(data, &cur)
+		}
+		if err != nil || cur.Sort != q.Sort || cur.Desc != q.Desc {
+			respondError(c, http.StatusBadRequest, "invalid_cursor", "cursor does not belong to this sort order", nil)
+			return q, false
+		}
+		q.After = &cur.Key
+	}
+	return q, true
+}
+
+// parseDateQuery accepts RFC 3339 times and plain dates. A plain date used as
+// an upper bound includes the whole day.
+func parseDateQuery(v string, end bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, v, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// matchName reports whether name contains the search text, ignoring case.
+func (q listQuery) matchName(name string) bool {
+	return q.Search == "" || strings.Contains(strings.ToLower(name), strings.ToLower(q.Search))
+}
+
+// inRange reports whether t lies in the date range. A nil time only matches
+// an open range.
+func (q listQuery) inRange(t *time.Time) bool {
+	if q.From.IsZero() && q.To.IsZero() {
+		return true
+	}
+	if t == nil {
+		return false
+	}
+	return (q.From.IsZero() || !t.Before(q.From)) && (q.To.IsZero() || t.Before(q.To))
+}
+
+// paginate sorts items by their key and cuts the page the query asks for.
+func paginate[T any](items []T, key func(*T) sortKey, q listQuery) ([]T, *Page) {
+	keys := make([]sortKey, len(items))
+	idx := make([]int, len(items))
+	for i := range items {
+		idx[i] = i
+		keys[i] = key(&items[i])
+	}
+	before := func(a, b sortKey) bool {
+		if q.Desc {
+			return a.compare(b) > 0
+		}
+		return a.compare(b) < 0
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return before(keys[idx[i]], keys[idx[j]]) })
+
+	page := &Page{Total: len(items), Limit: q.Limit}
+	start := 0
+	if q.After != nil {
+		start = sort.Search(len(idx), func(i int) bool { return before(*q.After, keys[idx[i]]) })
+	}
+	end := len(idx)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+		page.NextCursor = encodeCursor(q, keys[idx[end-1]])
+	}
+	out := make([]T, 0, end-start)
+	for _, i := range idx[start:end] {
+		out = append(out, items[i])
+	}
+	return out, page
+}
+
+// Bounds of the times UnixNano can represent.
+var (
+	minKeyTime = time.Unix(0, math.MinInt64)
+	maxKeyTime = time.Unix(0, math.MaxInt64)
+)
+
+// timeKey returns t in nanoseconds for a sort key, clamped so times outside
+// the int64 range, such as the zero time, still sort in order.
+func timeKey(t time.Time) int64 {
+	switch {
+	case t.Before(minKeyTime):
+		return math.MinInt64
+	case t.After(maxKeyTime):
+		return math.MaxInt64
+	}
+	return t.UnixNano()
+}
+
+// gameFilter holds the metadata filters of the game list.
+type gameFilter struct {
+	Platform string
+	Category string
+	Favorite *bool
+}
+
+// parseGameFilter reads platform, category and favorite from the query
+// string, responding 400 when a value is invalid.
+func parseGameFilter(c *gin.Context) (gameFilter, bool) {
+	f := gameFilter{
+		Platform: strings.TrimSpace(c.Query("platform")),
+		Category: strings.TrimSpace(c.Query("category")),
+	}
+	if c.Query("favorite") != "" {
+		fav, err := queryBool(c, "favorite", false)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "validation_error", "invalid favorite", nil)
+			return f, false
+		}
+		f.Favorite = &fav
+	}
+	return f, true
+}
+
+// match reports whether g passes the filters. Platform and category compare
+// case-insensitively.
+func (f gameFilter) match(g *model.Game) bool {
+	if f.Platform != "" && !strings.EqualFold(g.Platform, f.Platform) {
+		return false
+	}
+	if f.Category != "" && !strings.EqualFold(g.Category, f.Category) {
+		return false
+	}
+	if f.Favorite != nil && g.Favorite != *f.Favorite {
+		return false
+	}
+	return true
+}
+
+// gameSortKey returns the key of g for the query's sort; sizes holds the
+// backup size of each game.
+func gameSortKey(g *model.Game, sizes map[int64]int64, sortBy string) sortKey {
+	k := sortKey{ID: g.ID}
+	switch sortBy {
+	case sortName:
+		k.Str = strings.ToLower(g.Name)
+	case sortLastBackup:
+		if g.LastBackupAt != nil {
+			k.Num = timeKey(*g.LastBackupAt)
+		}
+	case sortSize:
+		k.Num = sizes[g.ID]
+	default:
+		k.Num = timeKey(g.CreatedAt)
+	}
+	return k
+}
+
+// backupSortKey returns the key of b for the query's sort.
+func backupSortKey(b *model.Backup, sortBy string) sortKey {
+	k := sortKey{ID: b.ID}
+	switch sortBy {
+	case sortName:
+		k.Str = strings.ToLower(b.Name)
+	case sortSize:
+		k.Num = b.SizeBytes
+	default:
+		k.Num = timeKey(b.CreatedAt)
+	}
+	return k
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gamebk/internal/model"
+)
+
+// listContext returns a gin context for GET /?query.
+func listContext(query url.Values) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?"+query.Encode(), nil)
+	return c, w
+}
+
+// testBackups has ties on every sort key, plus a zero and a pre-1970 time.
+func testBackups() []model.Backup {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return []model.Backup{
+		{ID: 1, Name: "b", CreatedAt: t0, SizeBytes: 10},
+		{ID: 2, Name: "a", CreatedAt: t0, SizeBytes: 10},
+		{ID: 3, Name: "B", CreatedAt: t0.Add(time.Hour), SizeBytes: 5},
+		{ID: 4, Name: "c", CreatedAt: time.Time{}, SizeBytes: 0},
+		{ID: 5, Name: "a", CreatedAt: time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), SizeBytes: 20},
+		{ID: 6, Name: "d", CreatedAt: t0.Add(-time.Hour), SizeBytes: 5},
+		{ID: 7, Name: "c", CreatedAt: t0, SizeBytes: 10},
+	}
+}
+
+func TestPaginateOrder(t *testing.T) {
+	tests := []struct {
+		sort  string
+		order string
+		want  []int64
+	}{
+		{sortCreated, "asc", []int64{4, 5, 6, 1, 2, 7, 3}},
+		{sortCreated, "desc", []int64{3, 7, 2, 1, 6, 5, 4}},
+		{sortName, "asc", []int64{2, 5, 1, 3, 4, 7, 6}},
+		{sortName, "desc", []int64{6, 7, 4, 3, 1, 5, 2}},
+		{sortSize, "asc", []int64{4, 3, 6, 1, 2, 7, 5}},
+		{sortSize, "desc", []int64{5, 7, 2, 1, 6, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort+" "+tt.order, func(t *testing.T) {
+			c, w := listContext(url.Values{"sort": {tt.sort}, "order": {tt.order}})
+			q, ok := parseListQuery(c, backupSorts)
+			if !ok {
+				t.Fatalf("parseListQuery: %s", w.Body)
+			}
+			items, page := paginate(testBackups(), func(b *model.Backup) sortKey { return backupSortKey(b, q.Sort) }, q)
+			if got := ids(items); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if page.Total != len(tt.want) || page.NextCursor != "" {
+				t.Errorf("page = %+v", page)
+			}
+		})
+	}
+}
+
+func TestPaginateCursor(t *testing.T) {
+	for sortBy := range backupSorts {
+		for _, order := range []string{"asc", "desc"} {
+			for limit := 1; limit <= 7; limit++ {
+				name := sortBy + " " + order + " " + strconv.Itoa(limit)
+				t.Run(name, func(t *testing.T) {
+					key := func(b *model.Backup) sortKey { return backupSortKey(b, sortBy) }
+					c, _ := listContext(url.Values{"sort": {sortBy}, "order": {order}})
+					q, _ := parseListQuery(c, backupSorts)
+					all, _ := paginate(testBackups(), key, q)
+
+					var got []int64
+					cur := ""
+					for pages := 0; ; pages++ {
+						if pages > len(all) {
+							t.Fatal("cursor does not advance")
+						}
+						v := url.Values{"sort": {sortBy}, "order": {order}, "limit": {strconv.Itoa(limit)}}
+						if cur != "" {
+							v.Set("cursor", cur)
+						}
+						c, w := listContext(v)
+						q, ok := parseListQuery(c, backupSorts)
+						if !ok {
+							t.Fatalf("parseListQuery: %s", w.Body)
+						}
+						items, page := paginate(testBackups(), key, q)
+						if len(items) > limit {
+							t.Fatalf("page has %d items, limit %d", len(items), limit)
+						}
+						got = append(got, ids(items)...)
+						if page.NextCursor == "" {
+							break
+						}
+						cur = page.NextCursor
+					}
+					if want := ids(all); !slices.Equal(got, want) {
+						t.Errorf("pages gave %v, want %v", got, want)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestParseListQueryCursorMismatch(t *testing.T) {
+	c, _ := listContext(url.Values{"sort": {sortName}, "limit": {"1"}})
+	q, _ := parseListQuery(c, backupSorts)
+	_, page := paginate(testBackups(), func(b *model.Backup) sortKey { return backupSortKey(b, q.Sort) }, q)
+	if page.NextCursor == "" {
+		t.Fatal("expected a next cursor")
+	}
+	tests := []url.Values{
+		{"sort": {sortSize}, "cursor": {page.NextCursor}},
+		{"sort": {sortName}, "order": {"desc"}, "cursor": {page.NextCursor}},
+		{"sort": {sortName}, "cursor": {"not-a-cursor"}},
+	}
+	for _, v := range tests {
+		c, w := listContext(v)
+		if _, ok := parseListQuery(c, backupSorts); ok || w.Code != http.StatusBadRequest {
+			t.Errorf("%v: ok = %v, status %d, want 400", v, ok, w.Code)
+		}
+	}
+}
+
+func TestTimeKey(t *testing.T) {
+	times := []time.Time{
+		{},
+		time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC),
+		time.Unix(0, 0),
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for i := 1; i < len(times); i++ {
+		if timeKey(times[i-1]) > timeKey(times[i]) {
+			t.Errorf("timeKey(%v) > timeKey(%v)", times[i-1], times[i])
+		}
+	}
+}
+
+func ids(items []model.Backup) []int64 {
+	out := make([]int64, len(items))
+	for i := range items {
+		out[i] = items[i].ID
+	}
+	return out
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"gamebk/internal/backup"
+	"gamebk/internal/manifest"
+	"gamebk/internal/model"
+	"gamebk/internal/pathvar"
+	"gamebk/internal/steam"
+)
+
+const (
+	defaultManifestLimit = 50
+	maxManifestLimit     = 500
+)
+
+// ManifestStatus reports where the game manifest is read from and how many
+// games it holds.
+func (h *Handler) ManifestStatus(c *gin.Context) {
+	st, err := h.Manifest.Status()
+	if err != nil {
+		respondManifestError(c, err)
+		return
+	}
+	respondOK(c, st)
+}
+
+// UploadManifest replaces the game manifest with the request body, raw or as
+// the multipart field "file". The file is parsed before it is installed.
+func (h *Handler) UploadManifest(c *gin.Context) {
+	body := io.Reader(c.Request.Body)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "bad_request", "file is required", err.Error())
+			return
+		}
+		f, err := fh.Open()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "bad_request", "failed to read file", err.Error())
+			return
+		}
+		defer func() { _ = f.Close() }()
+		body = f
+	}
+	if _, err := h.Manifest.Replace(body); err != nil {
+		respondManifestError(c, err)
+		return
+	}
+	h.ManifestStatus(c)
+}
+
+// SearchManifest lists manifest games whose name or alias contains q.
+func (h *Handler) SearchManifest(c *gin.Context) {
+	limit := defaultManifestLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxManifestLimit {
+			respondError(c, http.StatusBadRequest, "validation_error", "limit must be between 1 and 500", nil)
+			return
+		}
+		limit = n
+	}
+	m, err := h.Manifest.Get()
+	if err != nil {
+		respondManifestError(c, err)
+		return
+	}
+	respondOK(c, m.Search(c.Query("q"), limit))
+}
+
+// detectedGame is a detection with the game gamebk proposes to create.
+type detectedGame struct {
+	manifest.Detection
+	Game           model.Game `json:"game"`
+	ExistingGameID int64      `json:"existing_game_id,omitempty"`
+}
+
+// DetectGames checks the manifest's save locations on this machine and
+// proposes a game for every manifest entry with saves. With backup_root set,
+// each proposal backs up to a folder named after the game below it.
+func (h *Handler) DetectGames(c *gin.Context) {
+	backupRoot := strings.TrimSpace(c.Query("backup_root"))
+	m, err := h.Manifest.Get()
+	if err != nil {
+		respondManifestError(c, err)
+		return
+	}
+	games, err := h.Repo.Games.List(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to list games", err.Error())
+		return
+	}
+	existing := make(map[string]int64, len(games))
+	for _, g := range games {
+		existing[g.Name] = g.ID
+	}
+
+	apps := h.steamAppVars()
+	found := manifest.Detect(m, h.Paths, apps, c.Query("q"))
+	out := make([]detectedGame, 0, len(found))
+	for _, d := range found {
+		dg := detectedGame{
+			Detection:      d,
+			Game:           model.Game{Name: d.Name, GamePath: d.GamePath},
+			ExistingGameID: existing[d.Name],
+		}
+		if _, installed := apps[d.SteamID]; installed && d.SteamID != 0 {
+			dg.Game.SteamAppID = d.SteamID
+		}
+		if backupRoot != "" {
+			dg.Game.BackupRoot = filepath.Join(backupRoot, backup.SanitizeName(d.Name))
+		}
+		out = append(out, dg)
+	}
+	respondOK(c, out)
+}
+
+// steamAppVars returns the placeholders of every installed Steam app, or nil
+// when no Steam installation is found.
+func (h *Handler) steamAppVars() map[int64]pathvar.Vars {
+	root, err := h.Paths.Steam()
+	if err != nil {
+		return nil
+	}
+	apps, err := steam.Installation{Root: root}.Apps()
+	if err != nil {
+		return nil
+	}
+	out := make(map[int64]pathvar.Vars, len(apps))
+	for i := range apps {
+		out[apps[i].ID] = pathvar.AppVars(&apps[i])
+	}
+	return out
+}
+
+func respondManifestError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, manifest.ErrNotLoaded):
+		respondError(c, http.StatusNotFound, "manifest_not_loaded", "no game manifest has been loaded", err.Error())
+	case errors.Is(err, manifest.ErrInvalidManifest):
+		respondError(c, http.StatusBadRequest, "invalid_manifest", "failed to parse manifest", err.Error())
+	default:
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to read manifest", err.Error())
+	}
+}
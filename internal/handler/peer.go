@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gamebk/internal/backup"
+	"gamebk/internal/job"
+	"gamebk/internal/model"
+	"gamebk/internal/peer"
+	"gamebk/internal/repository"
+)
+
+func (h *Handler) CreatePeer(c *gin.Context) {
+	var req struct {
+		Name string `json:"name" binding:"required"`
+		URL  string `json:"url" binding:"required"`
+	}
+	if !bindAndValidate(c, &req) {
+		return
+	}
+	u, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		respondError(c, http.StatusBadRequest, "validation_error", "url must be an http(s) address", nil)
+		return
+	}
+
+	p := &model.Peer{
+		Name: strings.TrimSpace(req.Name),
+		URL:  strings.TrimRight(u.String(), "/"),
+	}
+	if err := h.Repo.Peers.Create(c.Request.Context(), p); err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to create peer", err.Error())
+		return
+	}
+	respondCreated(c, p)
+}
+
+func (h *Handler) ListPeers(c *gin.Context) {
+	peers, err := h.Repo.Peers.List(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to list peers", err.Error())
+		return
+	}
+	respondOK(c, peers)
+}
+
+func (h *Handler) DeletePeer(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("peerId"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, "bad_request", "invalid peer id", nil)
+		return
+	}
+	if err := h.Repo.Peers.DeleteByID(c.Request.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "not_found", "peer not found", nil)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to delete peer", err.Error())
+		return
+	}
+	respondOK(c, gin.H{"deleted": id})
+}
+
+func (h *Handler) PeerSyncStatus(c *gin.Context) {
+	p, ok := h.loadPeer(c)
+	if !ok {
+		return
+	}
+	status, err := h.Syncer.Status(c.Request.Context(), p)
+	if err != nil {
+		respondError(c, http.StatusBadGateway, "peer_error", "failed to reach peer", err.Error())
+		return
+	}
+	respondOK(c, status)
+}
+
+func (h *Handler) SyncPeer(c *gin.Context) {
+	p, ok := h.loadPeer(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		GameIDs   []int64 `json:"game_ids"`
+		Direction string  `json:"direction"`
+		Strategy  string  `json:"strategy"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, "bad_request", err.Error(), nil)
+			return
+		}
+	}
+
+	result, err := h.Syncer.Sync(c.Request.Context(), p, peer.Options{
+		GameIDs:   req.GameIDs,
+		Direction: req.Direction,
+		Strategy:  req.Strategy,
+	})
+	if err != nil {
+		if errors.Is(err, peer.ErrInvalidOptions) {
+			respondError(c, http.StatusBadRequest, "validation_error", err.Error(), nil)
+			return
+		}
+		respondError(c, http.StatusBadGateway, "peer_error", "sync failed", err.Error())
+		return
+	}
+	respondOK(c, result)
+}
+
+func (h *Handler) loadPeer(c *gin.Context) (*model.Peer, bool) {
+	id, err := strconv.ParseInt(c.Param("peerId"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, "bad_request", "invalid peer id", nil)
+		return nil, false
+	}
+	p, err := h.Repo.Peers.GetByID(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "not_found", "peer not found", nil)
+			return nil, false
+		}
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to load peer", err.Error())
+		return nil, false
+	}
+	return p, true
+}
+
+// DownloadBackupArchive streams a backup as a plain tar.gz without a manifest.
+// It is the transfer format of peers that predate bundles.
+func (h *Handler) DownloadBackupArchive(c *gin.Context) {
+	gameID, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	game, ok := h.loadGame(c, gameID)
+	if !ok {
+		return
+	}
+	b, ok := h.loadBackup(c, game.ID)
+	if !ok {
+		return
+	}
+	if _, err := os.Stat(b.BackupPath); err != nil {
+		respondError(c, http.StatusNotFound, "invalid_path", "backup files not found", err.Error())
+		return
+	}
+
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(b.Name, `"`, "")+`.tar.gz"`)
+	c.Status(http.StatusOK)
+	if err := backup.WriteTarGz(c.Writer, b.BackupPath); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// ReceiveBackupArchive registers a plain tar.gz body as a backup of the game,
+// used by peers that predate bundles. The name and created_at query parameters
+// are kept; the backup belongs to the default profile.
+func (h *Handler) ReceiveBackupArchive(c *gin.Context) {
+	id, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	name := strings.TrimSpace(c.Query("name"))
+	if !validateBackupName(c, name) {
+		return
+	}
+	createdAt := time.Now().UTC()
+	if v := c.Query("created_at"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "validation_error", "invalid created_at", err.Error())
+			return
+		}
+		createdAt = t.UTC()
+	}
+
+	game, ok := h.loadGame(c, id)
+	if !ok {
+		return
+	}
+	game, ok = h.resolveGame(c, game)
+	if !ok {
+		return
+	}
+	done, ok := h.startJob(c, game.ID, job.KindSync)
+	if !ok {
+		return
+	}
+	defer done()
+
+	if err := os.MkdirAll(game.BackupRoot, 0o755); err != nil {
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to create backup root", err.Error())
+		return
+	}
+	backupPath := filepath.Join(game.BackupRoot, name)
+	size, err := backup.ExtractTarGz(c.Request.Body, backupPath)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "io_error", "failed to extract archive", err.Error())
+		return
+	}
+
+	b := &model.Backup{
+		GameID:     game.ID,
+		Name:       name,
+		BackupPath: backupPath,
+		SizeBytes:  size,
+		CreatedAt:  createdAt,
+	}
+	if err := h.Repo.Backups.Create(c.Request.Context(), b); err != nil {
+		_ = os.RemoveAll(backupPath)
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to save backup", err.Error())
+		return
+	}
+	if game.LastBackupAt == nil || createdAt.After(*game.LastBackupAt) {
+		if err := h.Repo.Games.UpdateLastBackupAt(c.Request.Context(), game.ID, createdAt); err != nil {
+			respondError(c, http.StatusInternalServerError, "db_error", "failed to update game", err.Error())
+			return
+		}
+	}
+
+	auditBackup(c, b)
+	respondCreated(c, b)
+}
@@ -0,0 +1,305 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gamebk/internal/backup"
+	"gamebk/internal/job"
+	"gamebk/internal/library"
+	"gamebk/internal/model"
+	"gamebk/internal/repository"
+)
+
+// profileView is a save profile with a summary of its backups.
+type profileView struct {
+	Name         string     `json:"name"`
+	Active       bool       `json:"active"`
+	Backups      int        `json:"backups"`
+	LastBackupAt *time.Time `json:"last_backup_at"`
+}
+
+// ListProfiles lists the save profiles of a game, the default profile first.
+func (h *Handler) ListProfiles(c *gin.Context) {
+	id, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	game, ok := h.loadGame(c, id)
+	if !ok {
+		return
+	}
+	backups, err := h.Repo.Backups.ListByGameID(c.Request.Context(), game.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to list backups", err.Error())
+		return
+	}
+
+	names := append([]string{model.DefaultProfile}, game.Profiles...)
+	out := make([]profileView, 0, len(names))
+	byName := make(map[string]*profileView, len(names))
+	for _, name := range names {
+		out = append(out, profileView{Name: name, Active: name == game.ActiveProfile()})
+	}
+	for i := range out {
+		byName[out[i].Name] = &out[i]
+	}
+	// backups are newest first, so the first one seen is the latest
+	for _, b := range backups {
+		p, ok := byName[b.ProfileName()]
+		if !ok {
+			continue
+		}
+		p.Backups++
+		if p.LastBackupAt == nil {
+			t := b.CreatedAt
+			p.LastBackupAt = &t
+		}
+	}
+	respondOK(c, out)
+}
+
+// CreateProfile adds a named save profile to a game. The new profile has no
+// backups; switching to it starts with an empty save.
+func (h *Handler) CreateProfile(c *gin.Context) {
+	id, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if !bindAndValidate(c, &req) {
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if !validateBackupName(c, name) {
+		return
+	}
+	game, ok := h.loadGame(c, id)
+	if !ok {
+		return
+	}
+	if game.HasProfile(name) {
+		respondError(c, http.StatusConflict, "profile_exists", "a profile with this name already exists", name)
+		return
+	}
+
+	profiles := append(slices.Clone(game.Profiles), name)
+	if err := h.Repo.Games.UpdateProfiles(c.Request.Context(), game.ID, game.Profile, profiles); err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to update game", err.Error())
+		return
+	}
+	respondCreated(c, profileView{Name: name})
+}
+
+// DeleteProfile removes a named profile. The default profile, the active
+// profile and profiles that still have backups cannot be removed.
+func (h *Handler) DeleteProfile(c *gin.Context) {
+	id, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	name := c.Param("profile")
+	game, ok := h.loadGame(c, id)
+	if !ok {
+		return
+	}
+	switch {
+	case name == model.DefaultProfile:
+		respondError(c, http.StatusBadRequest, "bad_request", "the default profile cannot be deleted", nil)
+		return
+	case !game.HasProfile(name):
+		respondError(c, http.StatusNotFound, "not_found", "profile not found", nil)
+		return
+	case name == game.ActiveProfile():
+		respondError(c, http.StatusConflict, "profile_active", "switch to another profile before deleting this one", nil)
+		return
+	}
+	_, err := h.Repo.Backups.GetLatestByProfile(c.Request.Context(), game.ID, name)
+	if err == nil {
+		respondError(c, http.StatusConflict, "profile_not_empty", "delete the profile's backups first", nil)
+		return
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to load backups", err.Error())
+		return
+	}
+
+	profiles := slices.DeleteFunc(slices.Clone(game.Profiles), func(p string) bool { return p == name })
+	if err := h.Repo.Games.UpdateProfiles(c.Request.Context(), game.ID, game.Profile, profiles); err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to update game", err.Error())
+		return
+	}
+	respondOK(c, gin.H{"deleted": name})
+}
+
+// SwitchProfile puts another profile's save in place: the live save is backed
+// up into the active profile, then replaced by the latest backup of the target
+// profile, or cleared when the target has no backups yet. The new profile is
+// recorded before the save is replaced and reset when that fails; a full
+// restore copies the backup aside first, so a failed copy leaves the live save
+// as it was.
+func (h *Handler) SwitchProfile(c *gin.Context) {
+	id, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	name := c.Param("profile")
+	game, ok := h.loadGame(c, id)
+	if !ok {
+		return
+	}
+	if !game.HasProfile(name) {
+		respondError(c, http.StatusNotFound, "not_found", "profile not found", nil)
+		return
+	}
+	previous := game.ActiveProfile()
+	if name == previous {
+		respondError(c, http.StatusBadRequest, "validation_error", "profile is already active", nil)
+		return
+	}
+	ctx := c.Request.Context()
+	latest, err := h.Repo.Backups.GetLatestByProfile(ctx, game.ID, name)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to load backup", err.Error())
+		return
+	}
+
+	game, ok = h.resolveGame(c, game)
+	if !ok {
+		return
+	}
+	done, ok := h.startJob(c, game.ID, job.KindSwitch)
+	if !ok {
+		return
+	}
+	defer done()
+
+	saved, ok := h.backupLiveSave(c, game, previous)
+	if !ok {
+		return
+	}
+
+	// record the new profile before touching the save, so the save in place
+	// is never backed up into the wrong profile; a failed swap records the
+	// previous one again
+	if err := h.Repo.Games.UpdateProfiles(ctx, game.ID, model.StoredProfile(name), game.Profiles); err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to update game", err.Error())
+		return
+	}
+	var restored []string
+	if latest != nil {
+		restored, err = restoreBackupToGame(latest.BackupPath, game.GamePath, nil, library.SaveScope(game))
+	} else {
+		err = clearLiveSave(game)
+	}
+	if err != nil {
+		if rerr := h.Repo.Games.UpdateProfiles(ctx, game.ID, model.StoredProfile(previous), game.Profiles); rerr != nil {
+			err = fmt.Errorf("%w; restoring the previous profile failed: %v", err, rerr)
+		}
+		respondRestoreError(c, err)
+		return
+	}
+	auditBackup(c, latest)
+	respondOK(c, gin.H{
+		"profile":          name,
+		"previous_profile": previous,
+		"saved_backup":     saved,
+		"restored_backup":  latest,
+		"restored_paths":   restored,
+	})
+}
+
+// backupLiveSave backs the live save up into profile before a switch. It
+// returns a nil backup when there is no save to back up, and responds with an
+// error and returns false when the backup fails.
+func (h *Handler) backupLiveSave(c *gin.Context, game *model.Game, profile string) (*model.Backup, bool) {
+	if _, err := os.Stat(game.GamePath); errors.Is(err, os.ErrNotExist) {
+		return nil, true
+	}
+	if err := os.MkdirAll(game.BackupRoot, 0o755); err != nil {
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to create backup root", err.Error())
+		return nil, false
+	}
+	// switches may follow each other within a second, so number the name
+	// until it is free
+	base := "switch_" + time.Now().Format("20060102_150405")
+	name := base
+	backupPath := filepath.Join(game.BackupRoot, name)
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(backupPath); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		name = fmt.Sprintf("%s_%d", base, n)
+		backupPath = filepath.Join(game.BackupRoot, name)
+	}
+	size, err := backupGameFiles(game, backupPath)
+	if err != nil {
+		if errors.Is(err, backup.ErrNoMatch) {
+			return nil, true
+		}
+		respondError(c, http.StatusInternalServerError, "io_error", "backup failed", err.Error())
+		return nil, false
+	}
+
+	b := &model.Backup{
+		GameID:     game.ID,
+		Name:       name,
+		BackupPath: backupPath,
+		Profile:    model.StoredProfile(profile),
+		SizeBytes:  size,
+	}
+	ctx := c.Request.Context()
+	if err := h.Repo.Backups.Create(ctx, b); err != nil {
+		_ = os.RemoveAll(backupPath)
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to save backup", err.Error())
+		return nil, false
+	}
+	if err := h.Repo.Games.UpdateLastBackupAt(ctx, game.ID, time.Now()); err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to update game", err.Error())
+		return nil, false
+	}
+	return b, true
+}
+
+// adoptProfile adds the profile of a backup arriving from elsewhere to the
+// game when the game does not have it yet, so the backup shows up in its
+// profile. Responds 400 when the profile name is invalid.
+func (h *Handler) adoptProfile(c *gin.Context, game *model.Game, b *model.Backup) bool {
+	name := b.ProfileName()
+	if game.HasProfile(name) {
+		return true
+	}
+	if err := backup.ValidateName(name); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_profile", "invalid profile name", err.Error())
+		return false
+	}
+	if err := h.Repo.Games.AddProfile(c.Request.Context(), game.ID, name); err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to update game", err.Error())
+		return false
+	}
+	game.Profiles = append(game.Profiles, name)
+	return true
+}
+
+// clearLiveSave empties the save of a game switching to a profile without
+// backups: the whole save directory, or an emulator game's files only.
+func clearLiveSave(game *model.Game) error {
+	if scope := library.SaveScope(game); scope != nil {
+		_, err := backup.RemoveSelected(game.GamePath, scope)
+		return err
+	}
+	if err := backup.ClearDir(game.GamePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
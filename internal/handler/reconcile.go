@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"gamebk/internal/reconcile"
+	"gamebk/internal/repository"
+)
+
+// ScanBackups reports backup records whose folder is missing and folders under
+// each backup root that have no record, without changing anything.
+func (h *Handler) ScanBackups(c *gin.Context) {
+	var opts reconcile.Options
+	if v := c.Query("game_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			respondError(c, http.StatusBadRequest, "bad_request", "invalid game id", nil)
+			return
+		}
+		opts.GameID = id
+	}
+	h.runReconcile(c, opts)
+}
+
+// ReconcileBackups scans like ScanBackups, then prunes dead records and/or
+// adopts untracked folders as requested.
+func (h *Handler) ReconcileBackups(c *gin.Context) {
+	var req struct {
+		GameID int64 `json:"game_id"`
+		Prune  bool  `json:"prune"`
+		Adopt  bool  `json:"adopt"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "bad_request", err.Error(), nil)
+		return
+	}
+	if req.GameID < 0 {
+		respondError(c, http.StatusBadRequest, "bad_request", "invalid game id", nil)
+		return
+	}
+	if !req.Prune && !req.Adopt {
+		respondError(c, http.StatusBadRequest, "validation_error", "prune or adopt is required", nil)
+		return
+	}
+	h.runReconcile(c, reconcile.Options{GameID: req.GameID, Prune: req.Prune, Adopt: req.Adopt})
+}
+
+func (h *Handler) runReconcile(c *gin.Context, opts reconcile.Options) {
+	s := &reconcile.Scanner{Repo: h.Repo, Jobs: h.Jobs, Paths: h.Paths, Actor: strings.TrimSpace(c.GetHeader(actorHeader))}
+	report, err := s.Scan(c.Request.Context(), opts)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "not_found", "game not found", nil)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to scan backups", err.Error())
+		return
+	}
+	respondOK(c, report)
+}
@@ -14,16 +14,31 @@ type ErrorResponse struct {
 
 type SuccessResponse struct {
 	Data interface{} `json:"data"`
+	// Page is set by list endpoints.
+	Page *Page `json:"page,omitempty"`
+}
+
+// Page describes one page of a list: how many items match the filters and
+// the cursor of the next page, empty on the last one.
+type Page struct {
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 func respondOK(c *gin.Context, data interface{}) {
 	c.JSON(http.StatusOK, SuccessResponse{Data: data})
 }
 
+func respondPage(c *gin.Context, data interface{}, page *Page) {
+	c.JSON(http.StatusOK, SuccessResponse{Data: data, Page: page})
+}
+
 func respondCreated(c *gin.Context, data interface{}) {
 	c.JSON(http.StatusCreated, SuccessResponse{Data: data})
 }
 
 func respondError(c *gin.Context, status int, code, message string, details interface{}) {
+	c.Set(auditErrorKey, code)
 	c.JSON(status, ErrorResponse{Code: code, Message: message, Details: details})
 }
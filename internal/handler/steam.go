@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"gamebk/internal/manifest"
+	"gamebk/internal/model"
+	"gamebk/internal/pathvar"
+	"gamebk/internal/steam"
+)
+
+// steamUserDataPath is the default save location of a Steam game when the
+// manifest does not know one: the app's folder in the userdata of the account
+// pinned on the game.
+const steamUserDataPath = "<steam>/userdata/<storeUserId>/<storeGameId>"
+
+// ListSteamApps lists the apps installed in every Steam library.
+func (h *Handler) ListSteamApps(c *gin.Context) {
+	root, ok := h.steamRoot(c)
+	if !ok {
+		return
+	}
+	inst := steam.Installation{Root: root}
+	libs, err := inst.Libraries()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to read steam libraries", err.Error())
+		return
+	}
+	apps, err := inst.Apps()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to read steam apps", err.Error())
+		return
+	}
+	if apps == nil {
+		apps = []steam.App{}
+	}
+	respondOK(c, gin.H{"steam_root": root, "libraries": libs, "apps": apps})
+}
+
+// GetSteamApp returns one installed Steam app.
+func (h *Handler) GetSteamApp(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("appId"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, "bad_request", "invalid app id", nil)
+		return
+	}
+	app, ok := h.loadSteamApp(c, id)
+	if !ok {
+		return
+	}
+	respondOK(c, app)
+}
+
+func (h *Handler) steamRoot(c *gin.Context) (string, bool) {
+	root, err := h.Paths.Steam()
+	if err != nil {
+		respondError(c, http.StatusNotFound, "steam_not_found", "steam installation not found", err.Error())
+		return "", false
+	}
+	return root, true
+}
+
+// loadSteamApp finds an installed Steam app, responding 404 when Steam or the
+// app is missing.
+func (h *Handler) loadSteamApp(c *gin.Context, id int64) (*steam.App, bool) {
+	root, ok := h.steamRoot(c)
+	if !ok {
+		return nil, false
+	}
+	app, err := steam.Installation{Root: root}.App(id)
+	if err != nil {
+		if errors.Is(err, steam.ErrAppNotFound) {
+			respondError(c, http.StatusNotFound, "steam_app_not_found", "steam app is not installed", err.Error())
+			return nil, false
+		}
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to read steam app", err.Error())
+		return nil, false
+	}
+	return app, true
+}
+
+// pinSteamUser pins the Steam account of a game being linked to app: the one
+// given, which must be numeric, or else the one that played last. Responds
+// 400 for an invalid account.
+func pinSteamUser(c *gin.Context, game *model.Game, app *steam.App) bool {
+	if game.SteamUserID == "" {
+		if len(app.UserData) > 0 {
+			game.SteamUserID = app.UserData[0].UserID
+		}
+		return true
+	}
+	return validateSteamUser(c, game.SteamUserID)
+}
+
+// validateSteamUser responds 400 unless id is empty or a numeric Steam
+// account id.
+func validateSteamUser(c *gin.Context, id string) bool {
+	if id == "" {
+		return true
+	}
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		respondError(c, http.StatusBadRequest, "validation_error", "steam_user_id must be a numeric Steam account id", nil)
+		return false
+	}
+	return true
+}
+
+// steamGamePath proposes a save path template for a Steam app: the manifest's
+// save location when it has one that exists here, otherwise the app's userdata
+// folder. Returns "" when neither is known.
+func (h *Handler) steamGamePath(app *steam.App) string {
+	if m, err := h.Manifest.Get(); err == nil {
+		if g, ok := m.BySteamID(app.ID); ok {
+			if d, ok := manifest.DetectGame(g, h.Paths, pathvar.AppVars(app)); ok {
+				return d.GamePath
+			}
+		}
+	}
+	if len(app.UserData) > 0 {
+		return steamUserDataPath
+	}
+	return ""
+}
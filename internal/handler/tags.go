@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"gamebk/internal/model"
+	"gamebk/internal/repository"
+)
+
+const (
+	maxBackupTags  = 32
+	maxTagLength   = 64
+	maxNotesLength = 4000
+)
+
+// UpdateBackup edits the notes, tags and pinned flag of a backup. Omitted
+// fields are left unchanged; tags replaces the whole list.
+func (h *Handler) UpdateBackup(c *gin.Context) {
+	gameID, ok := parseGameID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Notes  *string   `json:"notes"`
+		Tags   *[]string `json:"tags"`
+		Pinned *bool     `json:"pinned"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "bad_request", err.Error(), nil)
+		return
+	}
+	if req.Notes == nil && req.Tags == nil && req.Pinned == nil {
+		respondError(c, http.StatusBadRequest, "validation_error", "no fields to update", nil)
+		return
+	}
+	if _, ok := h.loadGame(c, gameID); !ok {
+		return
+	}
+	b, ok := h.loadBackup(c, gameID)
+	if !ok {
+		return
+	}
+
+	if req.Notes != nil {
+		notes := strings.TrimSpace(*req.Notes)
+		if len(notes) > maxNotesLength {
+			respondError(c, http.StatusBadRequest, "validation_error", "notes must be at most 4000 bytes", nil)
+			return
+		}
+		b.Notes = notes
+	}
+	if req.Tags != nil {
+		tags, err := normalizeTags(*req.Tags)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "validation_error", err.Error(), nil)
+			return
+		}
+		b.Tags = tags
+	}
+	if req.Pinned != nil {
+		b.Pinned = *req.Pinned
+	}
+
+	if err := h.Repo.Backups.Update(c.Request.Context(), b); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "not_found", "backup not found", nil)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to update backup", err.Error())
+		return
+	}
+	auditBackup(c, b)
+	respondOK(c, b)
+}
+
+// normalizeTags trims the tags and drops empty and duplicate ones, keeping
+// their order.
+func normalizeTags(in []string) ([]string, error) {
+	out := make([]string, 0, len(in))
+	seen := make(map[string]bool, len(in))
+	for _, t := range in {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		if len(t) > maxTagLength {
+			return nil, errors.New("tags must be at most 64 bytes")
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	if len(out) > maxBackupTags {
+		return nil, errors.New("at most 32 tags are allowed")
+	}
+	return out, nil
+}
+
+// respondPinned rejects deleting a pinned backup.
+func respondPinned(c *gin.Context, b *model.Backup) {
+	respondError(c, http.StatusConflict, "backup_pinned", "unpin the backup before deleting it", gin.H{"backup_id": b.ID, "name": b.Name})
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gamebk/internal/backup"
+	"gamebk/internal/job"
+	"gamebk/internal/model"
+	"gamebk/internal/repository"
+	"gamebk/internal/trash"
+)
+
+// trashView is a trashed backup with the time it will be purged.
+type trashView struct {
+	*model.TrashedBackup
+	PurgeAt *time.Time `json:"purge_at,omitempty"`
+}
+
+// ListTrash lists the trashed backups, most recently deleted first. game_id
+// limits the list to one game.
+func (h *Handler) ListTrash(c *gin.Context) {
+	var gameID int64
+	if v := c.Query("game_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			respondError(c, http.StatusBadRequest, "bad_request", "invalid game id", nil)
+			return
+		}
+		gameID = id
+	}
+	items, err := h.Repo.Trash.List(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to list trash", err.Error())
+		return
+	}
+	out := make([]trashView, 0, len(items))
+	for i := range items {
+		if gameID != 0 && items[i].Backup.GameID != gameID {
+			continue
+		}
+		out = append(out, trashView{TrashedBackup: &items[i], PurgeAt: h.Trash.PurgeAt(&items[i])})
+	}
+	respondOK(c, out)
+}
+
+// RestoreTrash moves a trashed backup back to its folder and record. The game
+// must still exist and the backup's folder must be free.
+func (h *Handler) RestoreTrash(c *gin.Context) {
+	item, ok := h.loadTrashed(c)
+	if !ok {
+		return
+	}
+	game, ok := h.loadGame(c, item.Backup.GameID)
+	if !ok {
+		return
+	}
+	done, ok := h.startJob(c, game.ID, job.KindTrash)
+	if !ok {
+		return
+	}
+	defer done()
+	// a purge may have run while waiting for the job
+	if item, ok = h.loadTrashed(c); !ok {
+		return
+	}
+	b := &item.Backup
+
+	if item.TrashPath != "" {
+		if _, err := os.Lstat(b.BackupPath); err == nil {
+			respondError(c, http.StatusConflict, "backup_exists", "a backup with this name already exists", b.BackupPath)
+			return
+		}
+		if _, err := backup.MoveDir(item.TrashPath, b.BackupPath); err != nil {
+			respondError(c, http.StatusInternalServerError, "io_error", "failed to restore backup files", err.Error())
+			return
+		}
+	}
+	restored, err := h.Repo.Trash.Restore(c.Request.Context(), item.ID)
+	if err != nil {
+		if item.TrashPath != "" {
+			_, _ = backup.MoveDir(b.BackupPath, item.TrashPath)
+		}
+		if errors.Is(err, repository.ErrBackupExists) {
+			respondError(c, http.StatusConflict, "backup_exists", "the backup record exists again", nil)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to restore backup", err.Error())
+		return
+	}
+	if game.LastBackupAt == nil || restored.CreatedAt.After(*game.LastBackupAt) {
+		if err := h.Repo.Games.UpdateLastBackupAt(c.Request.Context(), game.ID, restored.CreatedAt); err != nil {
+			respondError(c, http.StatusInternalServerError, "db_error", "failed to update game", err.Error())
+			return
+		}
+	}
+	auditBackup(c, restored)
+	respondOK(c, restored)
+}
+
+// PurgeTrash deletes a trashed backup for good.
+func (h *Handler) PurgeTrash(c *gin.Context) {
+	item, ok := h.loadTrashed(c)
+	if !ok {
+		return
+	}
+	// restores run under the game's job too; reload in case one just ran
+	done, ok := h.startJob(c, item.Backup.GameID, job.KindTrash)
+	if !ok {
+		return
+	}
+	defer done()
+	if item, ok = h.loadTrashed(c); !ok {
+		return
+	}
+
+	if err := trash.Remove(c.Request.Context(), h.Repo, item); err != nil {
+		respondError(c, http.StatusInternalServerError, "io_error", "failed to purge backup", err.Error())
+		return
+	}
+	auditBackup(c, &item.Backup)
+	respondOK(c, gin.H{"purged": item.ID})
+}
+
+func (h *Handler) loadTrashed(c *gin.Context) (*model.TrashedBackup, bool) {
+	id, err := strconv.ParseInt(c.Param("trashId"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, "bad_request", "invalid trash id", nil)
+		return nil, false
+	}
+	item, err := h.Repo.Trash.GetByID(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "not_found", "trashed backup not found", nil)
+			return nil, false
+		}
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to load trashed backup", err.Error())
+		return nil, false
+	}
+	return item, true
+}
+
+// trashBackup moves a backup's folder into the trash of the game's resolved
+// backup root and its record into the trash bucket. When the record cannot be
+// moved the folder is put back.
+func (h *Handler) trashBackup(ctx context.Context, game *model.Game, b *model.Backup) (*model.TrashedBackup, error) {
+	trashPath, err := trash.Move(game.BackupRoot, b.BackupPath)
+	if err != nil {
+		return nil, err
+	}
+	item, err := h.Repo.Trash.Trash(ctx, b, trashPath)
+	if err != nil {
+		if trashPath != "" {
+			_, _ = backup.MoveDir(trashPath, b.BackupPath)
+		}
+		return nil, err
+	}
+	return item, nil
+}
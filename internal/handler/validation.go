@@ -6,6 +6,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+
+	"gamebk/internal/backup"
+	"gamebk/internal/library"
+	"gamebk/internal/model"
 )
 
 func bindAndValidate(c *gin.Context, dst interface{}) bool {
@@ -32,3 +36,83 @@ func formatValidationErrors(ve validator.ValidationErrors) []map[string]string {
 	}
 	return out
 }
+
+// resolveGame returns a copy of the game with its path variables resolved,
+// responding 400 when they cannot be resolved or the paths are unusable.
+func (h *Handler) resolveGame(c *gin.Context, game *model.Game) (*model.Game, bool) {
+	resolved, err := h.Paths.ResolveGame(game)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_path", err.Error(), nil)
+		return nil, false
+	}
+	if !validateGamePaths(c, resolved.GamePath, resolved.BackupRoot) {
+		return nil, false
+	}
+	return resolved, true
+}
+
+// checkScopeOverlap responds 409 with scope_overlap when game is an emulator
+// game whose saves overlap those of another emulator game in the same save
+// directory; restoring one would then overwrite or delete the other's saves.
+// game's paths must already be resolved.
+func (h *Handler) checkScopeOverlap(c *gin.Context, game *model.Game) bool {
+	if library.SaveScope(game) == nil {
+		return true
+	}
+	games, err := h.Repo.Games.List(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "db_error", "failed to list games", err.Error())
+		return false
+	}
+	other, err := library.ScopeConflict(h.Paths, game, games)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_path", err.Error(), nil)
+		return false
+	}
+	if other != nil {
+		respondError(c, http.StatusConflict, "scope_overlap", "the saves of this game overlap those of another emulator game in the same game_path", gin.H{"game_id": other.ID, "name": other.Name})
+		return false
+	}
+	return true
+}
+
+// validateGamePaths checks the save directory and backup root of a game,
+// responding 400 with invalid_path or path_overlap when they are unusable.
+func validateGamePaths(c *gin.Context, gamePath, backupRoot string) bool {
+	if err := backup.ValidateGamePaths(gamePath, backupRoot); err != nil {
+		if errors.Is(err, backup.ErrPathOverlap) {
+			respondError(c, http.StatusBadRequest, "path_overlap", "backup_root must not be inside game_path or contain it", gin.H{
+				"game_path":   gamePath,
+				"backup_root": backupRoot,
+			})
+			return false
+		}
+		respondError(c, http.StatusBadRequest, "invalid_path", err.Error(), nil)
+		return false
+	}
+	return true
+}
+
+// validateBackupName responds 400 with invalid_name when name cannot be used
+// as a backup folder name.
+func validateBackupName(c *gin.Context, name string) bool {
+	if err := backup.ValidateName(name); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_name", err.Error(), nil)
+		return false
+	}
+	return true
+}
+
+// validateGame checks the type specific fields and the library metadata of a
+// game, responding 400 when they are invalid.
+func validateGame(c *gin.Context, g *model.Game) bool {
+	if err := library.ValidateGame(g); err != nil {
+		code := "validation_error"
+		if errors.Is(err, backup.ErrUnsafePath) {
+			code = "invalid_pattern"
+		}
+		respondError(c, http.StatusBadRequest, code, err.Error(), nil)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package job
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	KindBackup    = "backup"
+	KindRestore   = "restore"
+	KindDelete    = "delete"
+	KindSync      = "sync"
+	KindReconcile = "reconcile"
+	KindImport    = "import"
+	KindUpload    = "upload"
+	KindSwitch    = "switch"
+	KindTrash     = "trash"
+	KindUpdate    = "update"
+)
+
+// Job is an operation currently running against a game.
+type Job struct {
+	GameID    int64     `json:"game_id"`
+	Kind      string    `json:"kind"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Tracker allows at most one running job per game.
+type Tracker struct {
+	mu      sync.Mutex
+	running map[int64]Job
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{running: make(map[int64]Job)}
+}
+
+// Start marks a job as running for the game. It returns false and the job
+// already running when the game is busy; otherwise done must be called once
+// the job finishes.
+func (t *Tracker) Start(gameID int64, kind string) (done func(), current Job, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if j, busy := t.running[gameID]; busy {
+		return nil, j, false
+	}
+	j := Job{GameID: gameID, Kind: kind, StartedAt: time.Now().UTC()}
+	t.running[gameID] = j
+	return func() {
+		t.mu.Lock()
+		delete(t.running, gameID)
+		t.mu.Unlock()
+	}, j, true
+}
+
+// Running returns the job running for the game, if any.
+func (t *Tracker) Running(gameID int64) (Job, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	j, ok := t.running[gameID]
+	return j, ok
+}
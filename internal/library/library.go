@@ -0,0 +1,499 @@
+// Package library exports the game library configuration as a versioned
+// document and imports it back, so configs can be kept outside the database.
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"gamebk/internal/backup"
+	"gamebk/internal/job"
+	"gamebk/internal/model"
+	"gamebk/internal/pathvar"
+	"gamebk/internal/repository"
+)
+
+// DocumentVersion is bumped whenever the document shape changes incompatibly.
+const DocumentVersion = 1
+
+// Import modes. Merge only adds games and reports differing ones; replace
+// makes the library match the document.
+const (
+	ModeMerge   = "merge"
+	ModeReplace = "replace"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported document version")
+	ErrInvalidDocument    = errors.New("invalid document")
+)
+
+type Document struct {
+	Version    int       `json:"version" yaml:"version"`
+	ExportedAt time.Time `json:"exported_at" yaml:"exported_at"`
+	Games      []Game    `json:"games" yaml:"games"`
+}
+
+type Game struct {
+	Name         string   `json:"name" yaml:"name"`
+	GamePath     string   `json:"game_path" yaml:"game_path"`
+	BackupRoot   string   `json:"backup_root" yaml:"backup_root"`
+	SteamAppID   int64    `json:"steam_app_id,omitempty" yaml:"steam_app_id,omitempty"`
+	Type         string   `json:"type,omitempty" yaml:"type,omitempty"`
+	RomName      string   `json:"rom_name,omitempty" yaml:"rom_name,omitempty"`
+	SavePatterns []string `json:"save_patterns,omitempty" yaml:"save_patterns,omitempty"`
+	Profile      string   `json:"profile,omitempty" yaml:"profile,omitempty"`
+	Profiles     []string `json:"profiles,omitempty" yaml:"profiles,omitempty"`
+	Platform     string   `json:"platform,omitempty" yaml:"platform,omitempty"`
+	Category     string   `json:"category,omitempty" yaml:"category,omitempty"`
+	Favorite     bool     `json:"favorite,omitempty" yaml:"favorite,omitempty"`
+	Backups      []Backup `json:"backups,omitempty" yaml:"backups,omitempty"`
+}
+
+type Backup struct {
+	Name       string    `json:"name" yaml:"name"`
+	BackupPath string    `json:"backup_path" yaml:"backup_path"`
+	Profile    string    `json:"profile,omitempty" yaml:"profile,omitempty"`
+	Notes      string    `json:"notes,omitempty" yaml:"notes,omitempty"`
+	Tags       []string  `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Pinned     bool      `json:"pinned,omitempty" yaml:"pinned,omitempty"`
+	CreatedAt  time.Time `json:"created_at" yaml:"created_at"`
+	SizeBytes  int64     `json:"size_bytes" yaml:"size_bytes"`
+}
+
+// Export builds a document of every game, optionally with backup metadata.
+func Export(ctx context.Context, repo *repository.Repository, includeBackups bool) (*Document, error) {
+	games, err := repo.Games.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{
+		Version:    DocumentVersion,
+		ExportedAt: time.Now().UTC(),
+		Games:      make([]Game, 0, len(games)),
+	}
+	// List returns newest first; export in creation order for stable diffs
+	for i := len(games) - 1; i >= 0; i-- {
+		g := games[i]
+		out := Game{
+			Name:         g.Name,
+			GamePath:     g.GamePath,
+			BackupRoot:   g.BackupRoot,
+			SteamAppID:   g.SteamAppID,
+			Type:         g.Type,
+			RomName:      g.RomName,
+			SavePatterns: g.SavePatterns,
+			Profile:      g.Profile,
+			Profiles:     g.Profiles,
+			Platform:     g.Platform,
+			Category:     g.Category,
+			Favorite:     g.Favorite,
+		}
+		if includeBackups {
+			backups, err := repo.Backups.ListByGameID(ctx, g.ID)
+			if err != nil {
+				return nil, err
+			}
+			for j := len(backups) - 1; j >= 0; j-- {
+				b := backups[j]
+				out.Backups = append(out.Backups, Backup{
+					Name:       b.Name,
+					BackupPath: b.BackupPath,
+					Profile:    b.Profile,
+					Notes:      b.Notes,
+					Tags:       b.Tags,
+					Pinned:     b.Pinned,
+					CreatedAt:  b.CreatedAt,
+					SizeBytes:  b.SizeBytes,
+				})
+			}
+		}
+		doc.Games = append(doc.Games, out)
+	}
+	return doc, nil
+}
+
+// Result actions reported per game by Import.
+const (
+	ActionCreated   = "created"
+	ActionUnchanged = "unchanged"
+	ActionReplaced  = "replaced"
+	ActionDeleted   = "deleted"
+	ActionConflict  = "conflict"
+)
+
+type GameResult struct {
+	Name            string   `json:"name"`
+	GameID          int64    `json:"game_id,omitempty"`
+	Action          string   `json:"action"`
+	Conflicts       []string `json:"conflicts,omitempty"`
+	BackupsImported int      `json:"backups_imported,omitempty"`
+	BackupsSkipped  []string `json:"backups_skipped,omitempty"`
+}
+
+// Import applies a document. Games are matched by name: unknown games are
+// created; in merge mode a game whose settings differ is reported as a
+// conflict and left untouched, in replace mode its settings and profiles are
+// overwritten and games missing from the document are deleted with their
+// backup records, leaving the backup folders on disk. A game with a running
+// job (when jobs is set) is neither replaced nor deleted, nor is one with
+// pinned backups deleted or one replaced whose dropped profile has backups;
+// those are reported as conflicts. Backup
+// metadata is registered for backups whose folder exists and whose name is
+// not yet recorded. Paths are kept as written and validated after resolving
+// their variables with paths.
+func Import(ctx context.Context, repo *repository.Repository, jobs *job.Tracker, paths *pathvar.Resolver, doc *Document, mode string) ([]GameResult, error) {
+	if doc.Version < 1 || doc.Version > DocumentVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, doc.Version)
+	}
+	if mode == "" {
+		mode = ModeMerge
+	}
+	if mode != ModeMerge && mode != ModeReplace {
+		return nil, fmt.Errorf("%w: unknown import mode %q", ErrInvalidDocument, mode)
+	}
+	seen := make(map[string]bool, len(doc.Games))
+	for _, g := range doc.Games {
+		if g.Name == "" || g.GamePath == "" || g.BackupRoot == "" {
+			return nil, fmt.Errorf("%w: game %q: name, game_path and backup_root are required", ErrInvalidDocument, g.Name)
+		}
+		resolved, err := paths.ResolveGame(&model.Game{GamePath: g.GamePath, BackupRoot: g.BackupRoot, SteamAppID: g.SteamAppID})
+		if err == nil {
+			err = backup.ValidateGamePaths(resolved.GamePath, resolved.BackupRoot)
+		}
+		if err == nil {
+			err = backup.ValidatePatterns(g.SavePatterns)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: game %q: %v", ErrInvalidDocument, g.Name, err)
+		}
+		if err := checkProfiles(g); err != nil {
+			return nil, fmt.Errorf("%w: game %q: %v", ErrInvalidDocument, g.Name, err)
+		}
+		if seen[g.Name] {
+			return nil, fmt.Errorf("%w: game %q appears more than once", ErrInvalidDocument, g.Name)
+		}
+		seen[g.Name] = true
+	}
+
+	existing, err := repo.Games.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*model.Game, len(existing))
+	for i := range existing {
+		byName[existing[i].Name] = &existing[i]
+	}
+	kept := existing
+	if mode == ModeReplace {
+		kept = nil
+	}
+	if err := checkScopes(paths, doc.Games, kept); err != nil {
+		return nil, err
+	}
+
+	out := make([]GameResult, 0, len(doc.Games))
+	for _, g := range doc.Games {
+		res := GameResult{Name: g.Name}
+		current, ok := byName[g.Name]
+		switch {
+		case !ok:
+			current = &model.Game{
+				Name:         g.Name,
+				GamePath:     g.GamePath,
+				BackupRoot:   g.BackupRoot,
+				SteamAppID:   g.SteamAppID,
+				Type:         g.Type,
+				RomName:      g.RomName,
+				SavePatterns: g.SavePatterns,
+				Profile:      model.StoredProfile(g.Profile),
+				Profiles:     g.Profiles,
+				Platform:     g.Platform,
+				Category:     g.Category,
+				Favorite:     g.Favorite,
+			}
+			if err := repo.Games.Create(ctx, current); err != nil {
+				return nil, err
+			}
+			res.Action = ActionCreated
+		default:
+			res.Conflicts = diff(current, g)
+			switch {
+			case len(res.Conflicts) == 0:
+				res.Action = ActionUnchanged
+			case mode == ModeReplace:
+				if err := replaceGame(ctx, repo, jobs, current, g, &res); err != nil {
+					return nil, err
+				}
+			default:
+				res.Action = ActionConflict
+			}
+		}
+		res.GameID = current.ID
+
+		if res.Action != ActionConflict && len(g.Backups) > 0 {
+			if err := importBackups(ctx, repo, current, g.Backups, &res); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, res)
+	}
+
+	if mode == ModeReplace {
+		for i := range existing {
+			if seen[existing[i].Name] {
+				continue
+			}
+			res, err := deleteGame(ctx, repo, jobs, &existing[i])
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, res)
+		}
+	}
+	return out, nil
+}
+
+// replaceGame overwrites the configuration and profiles of a game with its
+// document entry, unless a job is running for it or a profile it drops still
+// has backups; those are reported as conflicts.
+func replaceGame(ctx context.Context, repo *repository.Repository, jobs *job.Tracker, current *model.Game, g Game, res *GameResult) error {
+	res.Action = ActionConflict
+	if jobs != nil {
+		done, running, ok := jobs.Start(current.ID, job.KindUpdate)
+		if !ok {
+			res.Conflicts = append(res.Conflicts, running.Kind+" job is running")
+			return nil
+		}
+		defer done()
+	}
+	var blocked []string
+	for _, p := range current.Profiles {
+		if slices.Contains(g.Profiles, p) {
+			continue
+		}
+		_, err := repo.Backups.GetLatestByProfile(ctx, current.ID, p)
+		if err == nil {
+			blocked = append(blocked, "profile "+p+" has backups")
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+	}
+	if len(blocked) > 0 {
+		res.Conflicts = append(res.Conflicts, blocked...)
+		return nil
+	}
+
+	current.GamePath = g.GamePath
+	current.BackupRoot = g.BackupRoot
+	current.SteamAppID = g.SteamAppID
+	current.Type = g.Type
+	current.RomName = g.RomName
+	current.SavePatterns = g.SavePatterns
+	current.Platform = g.Platform
+	current.Category = g.Category
+	current.Favorite = g.Favorite
+	if err := repo.Games.Update(ctx, current); err != nil {
+		return err
+	}
+	current.Profile = model.StoredProfile(g.Profile)
+	current.Profiles = g.Profiles
+	if err := repo.Games.UpdateProfiles(ctx, current.ID, current.Profile, current.Profiles); err != nil {
+		return err
+	}
+	res.Action = ActionReplaced
+	return nil
+}
+
+// checkProfiles checks the profile names of a document entry and that its
+// active profile is one of them.
+func checkProfiles(g Game) error {
+	for _, p := range g.Profiles {
+		if p == model.DefaultProfile {
+			return fmt.Errorf("profiles must not list %q", model.DefaultProfile)
+		}
+		if err := backup.ValidateName(p); err != nil {
+			return fmt.Errorf("profile: %w", err)
+		}
+	}
+	if active := model.StoredProfile(g.Profile); active != "" && !slices.Contains(g.Profiles, active) {
+		return fmt.Errorf("profile %q is not listed in profiles", g.Profile)
+	}
+	return nil
+}
+
+// deleteGame removes a game missing from a replacing document, unless it has
+// pinned backups or a running job.
+func deleteGame(ctx context.Context, repo *repository.Repository, jobs *job.Tracker, g *model.Game) (GameResult, error) {
+	res := GameResult{Name: g.Name, GameID: g.ID, Action: ActionConflict}
+	if jobs != nil {
+		done, current, ok := jobs.Start(g.ID, job.KindDelete)
+		if !ok {
+			res.Conflicts = []string{current.Kind + " job is running"}
+			return res, nil
+		}
+		defer done()
+	}
+	backups, err := repo.Backups.ListByGameID(ctx, g.ID)
+	if err != nil {
+		return res, err
+	}
+	for _, b := range backups {
+		if b.Pinned {
+			res.Conflicts = append(res.Conflicts, "pinned backup "+b.Name)
+		}
+	}
+	if len(res.Conflicts) > 0 {
+		return res, nil
+	}
+	if err := repo.Games.Delete(ctx, g.ID); err != nil {
+		return res, err
+	}
+	res.Action = ActionDeleted
+	return res, nil
+}
+
+func importBackups(ctx context.Context, repo *repository.Repository, g *model.Game, backups []Backup, res *GameResult) error {
+	existing, err := repo.Backups.ListByGameID(ctx, g.ID)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(existing))
+	for _, b := range existing {
+		names[b.Name] = true
+	}
+
+	var latest time.Time
+	for _, b := range backups {
+		if names[b.Name] {
+			continue
+		}
+		if _, err := os.Stat(b.BackupPath); err != nil {
+			res.BackupsSkipped = append(res.BackupsSkipped, b.Name)
+			continue
+		}
+		rec := &model.Backup{
+			GameID:     g.ID,
+			Name:       b.Name,
+			BackupPath: b.BackupPath,
+			Profile:    model.StoredProfile(b.Profile),
+			Notes:      b.Notes,
+			Tags:       b.Tags,
+			Pinned:     b.Pinned,
+			SizeBytes:  b.SizeBytes,
+			CreatedAt:  b.CreatedAt,
+		}
+		if profile := rec.ProfileName(); !g.HasProfile(profile) {
+			if backup.ValidateName(profile) != nil {
+				res.BackupsSkipped = append(res.BackupsSkipped, b.Name)
+				continue
+			}
+			if err := repo.Games.AddProfile(ctx, g.ID, profile); err != nil {
+				return err
+			}
+			g.Profiles = append(g.Profiles, profile)
+		}
+		if err := repo.Backups.Create(ctx, rec); err != nil {
+			return err
+		}
+		names[b.Name] = true
+		res.BackupsImported++
+		if rec.CreatedAt.After(latest) {
+			latest = rec.CreatedAt
+		}
+	}
+	if res.BackupsImported > 0 && (g.LastBackupAt == nil || latest.After(*g.LastBackupAt)) {
+		return repo.Games.UpdateLastBackupAt(ctx, g.ID, latest)
+	}
+	return nil
+}
+
+// checkScopes rejects a document whose emulator games would claim the same
+// saves as another emulator game in the same save directory, either one from
+// the document or one of the existing games that stay.
+func checkScopes(paths *pathvar.Resolver, games []Game, existing []model.Game) error {
+	paths = paths.Cached()
+	named := make(map[string]bool, len(games))
+	var all []*model.Game
+	for _, g := range games {
+		named[g.Name] = true
+		all = append(all, &model.Game{Name: g.Name, GamePath: g.GamePath, SteamAppID: g.SteamAppID, Type: g.Type, RomName: g.RomName, SavePatterns: g.SavePatterns})
+	}
+	for i := range existing {
+		if !named[existing[i].Name] {
+			all = append(all, &existing[i])
+		}
+	}
+	for i, a := range all {
+		if a.Type != model.GameTypeEmulator {
+			continue
+		}
+		ra, err := paths.ResolveGame(a)
+		if err != nil {
+			continue
+		}
+		for _, b := range all[i+1:] {
+			if b.Type != model.GameTypeEmulator {
+				continue
+			}
+			rb, err := paths.ResolveGame(b)
+			if err != nil || filepath.Clean(ra.GamePath) != filepath.Clean(rb.GamePath) {
+				continue
+			}
+			overlap, err := backup.ScopesOverlap(ra.GamePath,
+				backup.EmulatorScope(a.RomName, a.SavePatterns), backup.EmulatorScope(b.RomName, b.SavePatterns))
+			if err != nil {
+				return fmt.Errorf("%w: game %q: %v", ErrInvalidDocument, a.Name, err)
+			}
+			if overlap {
+				return fmt.Errorf("%w: the saves of games %q and %q overlap in %s", ErrInvalidDocument, a.Name, b.Name, ra.GamePath)
+			}
+		}
+	}
+	return nil
+}
+
+// diff lists the fields whose value differs between the stored game and the
+// document entry.
+func diff(current *model.Game, g Game) []string {
+	var out []string
+	if current.GamePath != g.GamePath {
+		out = append(out, "game_path")
+	}
+	if current.BackupRoot != g.BackupRoot {
+		out = append(out, "backup_root")
+	}
+	if current.SteamAppID != g.SteamAppID {
+		out = append(out, "steam_app_id")
+	}
+	if current.Type != g.Type {
+		out = append(out, "type")
+	}
+	if current.RomName != g.RomName {
+		out = append(out, "rom_name")
+	}
+	if !slices.Equal(current.SavePatterns, g.SavePatterns) {
+		out = append(out, "save_patterns")
+	}
+	if current.Platform != g.Platform {
+		out = append(out, "platform")
+	}
+	if current.Category != g.Category {
+		out = append(out, "category")
+	}
+	if current.Favorite != g.Favorite {
+		out = append(out, "favorite")
+	}
+	if current.Profile != model.StoredProfile(g.Profile) {
+		out = append(out, "profile")
+	}
+	if !slices.Equal(current.Profiles, g.Profiles) {
+		out = append(out, "profiles")
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package library
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"gamebk/internal/backup"
+	"gamebk/internal/model"
+	"gamebk/internal/pathvar"
+)
+
+// MaxMetaLength bounds the free-form platform and category of a game.
+const MaxMetaLength = 64
+
+// ValidateGame checks the type specific fields and the library metadata of a
+// game definition, as the API does before creating or changing a game.
+// Invalid save patterns wrap backup.ErrUnsafePath.
+func ValidateGame(g *model.Game) error {
+	switch g.Type {
+	case "", model.GameTypeStandard:
+		if g.RomName != "" || len(g.SavePatterns) > 0 {
+			return errors.New("rom_name and save_patterns require type emulator")
+		}
+	case model.GameTypeEmulator:
+		if g.RomName == "" && len(g.SavePatterns) == 0 {
+			return errors.New("rom_name or save_patterns is required for emulator games")
+		}
+		if strings.ContainsAny(g.RomName, `/\`) {
+			return errors.New("rom_name must not contain path separators")
+		}
+		if err := backup.ValidatePatterns(g.SavePatterns); err != nil {
+			return err
+		}
+	default:
+		return errors.New("type must be standard or emulator")
+	}
+	if len(g.Platform) > MaxMetaLength || len(g.Category) > MaxMetaLength {
+		return errors.New("platform and category must be at most 64 bytes")
+	}
+	return nil
+}
+
+// SaveScope returns the saves of an emulator game within its save directory,
+// or nil for a game that owns the whole directory.
+func SaveScope(g *model.Game) *backup.Scope {
+	if g.Type != model.GameTypeEmulator {
+		return nil
+	}
+	return backup.EmulatorScope(g.RomName, g.SavePatterns)
+}
+
+// ScopeConflict returns the emulator game of games whose saves overlap those
+// of g in the same save directory, or nil; restoring one would then overwrite
+// or delete the other's saves. g's paths must already be resolved; games that
+// cannot be resolved are compared by their stored paths.
+func ScopeConflict(paths *pathvar.Resolver, g *model.Game, games []model.Game) (*model.Game, error) {
+	scope := SaveScope(g)
+	if scope == nil {
+		return nil, nil
+	}
+	paths = paths.Cached()
+	for i := range games {
+		other := &games[i]
+		if other.ID == g.ID || other.Type != model.GameTypeEmulator {
+			continue
+		}
+		resolved := other
+		if r, err := paths.ResolveGame(other); err == nil {
+			resolved = r
+		}
+		if filepath.Clean(resolved.GamePath) != filepath.Clean(g.GamePath) {
+			continue
+		}
+		overlap, err := backup.ScopesOverlap(g.GamePath, scope, SaveScope(other))
+		if err != nil {
+			return nil, err
+		}
+		if overlap {
+			return other, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,108 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gamebk/internal/pathvar"
+)
+
+// storeGlobs are manifest placeholders that stand for store specific ids and
+// are matched with a wildcard during detection.
+var storeGlobs = strings.NewReplacer("<storeUserId>", "*", "<storeGameId>", "*")
+
+// installPlaceholders need the game's install directory, which is only known
+// for installed Steam apps.
+var installPlaceholders = []string{"<root>", "<game>", "<base>"}
+
+// Detection is a manifest game with saves found on this machine.
+type Detection struct {
+	Name    string `json:"name"`
+	SteamID int64  `json:"steam_id,omitempty"`
+	// Matches are the existing paths matched by the game's file rules.
+	Matches []string `json:"matches"`
+	// GamePath is the proposed save directory: the rule's template when it
+	// names a directory without wildcards, otherwise the first matched
+	// directory.
+	GamePath         string `json:"game_path"`
+	ResolvedGamePath string `json:"resolved_game_path"`
+}
+
+// Detect checks the file rules of every game whose name contains query and
+// returns the games with at least one existing save path, sorted by name.
+// apps maps installed Steam app ids to their placeholders, so rules below a
+// Steam game's install directory can be checked too. Rules for other
+// operating systems and rules with variables that do not resolve here are
+// skipped.
+func Detect(m *Manifest, paths *pathvar.Resolver, apps map[int64]pathvar.Vars, query string) []Detection {
+	games := m.Search(query, 0)
+	out := []Detection{}
+	for _, g := range games {
+		if d, ok := DetectGame(g, paths, apps[g.SteamID]); ok {
+			out = append(out, d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// DetectGame checks the file rules of one game. vars holds the install
+// location placeholders of the game, if known.
+func DetectGame(g *Game, paths *pathvar.Resolver, vars pathvar.Vars) (Detection, bool) {
+	d := Detection{Name: g.Name, SteamID: g.SteamID}
+	seen := make(map[string]bool)
+	for _, f := range g.Files {
+		if !f.Applies() || (vars == nil && needsInstallDir(f.Path)) {
+			continue
+		}
+		template := storeGlobs.Replace(f.Path)
+		pattern, err := paths.ResolveWith(template, vars)
+		if err != nil {
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			if seen[match] {
+				continue
+			}
+			info, err := os.Stat(match)
+			if err != nil {
+				continue
+			}
+			seen[match] = true
+			d.Matches = append(d.Matches, match)
+			if d.GamePath != "" {
+				continue
+			}
+			dir := match
+			if !info.IsDir() {
+				dir = filepath.Dir(match)
+			}
+			d.ResolvedGamePath = dir
+			d.GamePath = dir
+			if info.IsDir() && !hasGlob(template) {
+				d.GamePath = f.Path
+			}
+		}
+	}
+	return d, len(d.Matches) > 0
+}
+
+func needsInstallDir(p string) bool {
+	for _, ph := range installPlaceholders {
+		if strings.Contains(p, ph) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
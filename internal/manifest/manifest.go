@@ -0,0 +1,313 @@
+// Package manifest loads a database of known games and their save locations
+// in the format of the Ludusavi manifest
+// (https://github.com/mtkennerly/ludusavi-manifest), searches it and detects
+// which of its games have saves on this machine.
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// ErrNotLoaded is returned when no manifest file exists at the store's path.
+var ErrNotLoaded = errors.New("manifest not loaded")
+
+// ErrInvalidManifest is returned when a manifest file cannot be parsed.
+var ErrInvalidManifest = errors.New("invalid manifest")
+
+// Constraint limits a file rule to an operating system and/or store. Empty
+// fields match anything.
+type Constraint struct {
+	OS    string `json:"os,omitempty" yaml:"os"`
+	Store string `json:"store,omitempty" yaml:"store"`
+}
+
+// File is one save location of a game. Path is a template using the
+// manifest's placeholders and may contain glob patterns.
+type File struct {
+	Path string       `json:"path"`
+	Tags []string     `json:"tags,omitempty"`
+	When []Constraint `json:"when,omitempty"`
+}
+
+// Game is a manifest entry.
+type Game struct {
+	Name        string   `json:"name"`
+	SteamID     int64    `json:"steam_id,omitempty"`
+	InstallDirs []string `json:"install_dirs,omitempty"`
+	Files       []File   `json:"files"`
+}
+
+type rawFile struct {
+	Tags []string     `yaml:"tags"`
+	When []Constraint `yaml:"when"`
+}
+
+type rawStore struct {
+	ID int64 `yaml:"id"`
+}
+
+type rawEntry struct {
+	Files      map[string]rawFile `yaml:"files"`
+	InstallDir map[string]any     `yaml:"installDir"`
+	Steam      *rawStore          `yaml:"steam"`
+	Alias      string             `yaml:"alias"`
+}
+
+// Manifest is a parsed manifest. Alias entries are folded into Aliases and
+// left out of Games.
+type Manifest struct {
+	Games   map[string]*Game
+	Aliases map[string]string
+	names   []string
+}
+
+// Parse reads a manifest in Ludusavi's YAML format.
+func Parse(data []byte) (*Manifest, error) {
+	var raw map[string]rawEntry
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+	m := &Manifest{Games: make(map[string]*Game, len(raw)), Aliases: make(map[string]string)}
+	for name, e := range raw {
+		if e.Alias != "" {
+			m.Aliases[name] = e.Alias
+			continue
+		}
+		g := &Game{Name: name, Files: make([]File, 0, len(e.Files))}
+		if e.Steam != nil {
+			g.SteamID = e.Steam.ID
+		}
+		for dir := range e.InstallDir {
+			g.InstallDirs = append(g.InstallDirs, dir)
+		}
+		sort.Strings(g.InstallDirs)
+		for p, f := range e.Files {
+			g.Files = append(g.Files, File{Path: p, Tags: f.Tags, When: f.When})
+		}
+		sort.Slice(g.Files, func(i, j int) bool { return g.Files[i].Path < g.Files[j].Path })
+		m.Games[name] = g
+		m.names = append(m.names, name)
+	}
+	sort.Strings(m.names)
+	return m, nil
+}
+
+// Len returns the number of games, not counting aliases.
+func (m *Manifest) Len() int {
+	return len(m.Games)
+}
+
+// Lookup returns the game with the given name or alias.
+func (m *Manifest) Lookup(name string) (*Game, bool) {
+	if target, ok := m.Aliases[name]; ok {
+		name = target
+	}
+	g, ok := m.Games[name]
+	return g, ok
+}
+
+// BySteamID returns the game with the given Steam app id.
+func (m *Manifest) BySteamID(id int64) (*Game, bool) {
+	for _, name := range m.names {
+		if g := m.Games[name]; g.SteamID == id {
+			return g, true
+		}
+	}
+	return nil, false
+}
+
+// Search returns up to limit games whose name or alias contains query, case
+// insensitively. Exact matches come first, then prefix matches, then the rest
+// in name order. An empty query matches every game.
+func (m *Manifest) Search(query string, limit int) []*Game {
+	q := strings.ToLower(strings.TrimSpace(query))
+	rank := make(map[string]int)
+	consider := func(name, target string) {
+		lower := strings.ToLower(name)
+		r := -1
+		switch {
+		case q == "" || lower == q:
+			r = 0
+		case strings.HasPrefix(lower, q):
+			r = 1
+		case strings.Contains(lower, q):
+			r = 2
+		}
+		if r < 0 {
+			return
+		}
+		if cur, ok := rank[target]; !ok || r < cur {
+			rank[target] = r
+		}
+	}
+	for _, name := range m.names {
+		consider(name, name)
+	}
+	for alias, target := range m.Aliases {
+		if _, ok := m.Games[target]; ok {
+			consider(alias, target)
+		}
+	}
+
+	names := make([]string, 0, len(rank))
+	for name := range rank {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if rank[names[i]] != rank[names[j]] {
+			return rank[names[i]] < rank[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if limit > 0 && len(names) > limit {
+		names = names[:limit]
+	}
+	out := make([]*Game, 0, len(names))
+	for _, name := range names {
+		out = append(out, m.Games[name])
+	}
+	return out
+}
+
+// currentOS is this platform's name in manifest constraints.
+func currentOS() string {
+	switch runtime.GOOS {
+	case "windows":
+		return "windows"
+	case "darwin":
+		return "mac"
+	default:
+		return "linux"
+	}
+}
+
+// Applies reports whether the rule can match on this operating system.
+func (f File) Applies() bool {
+	if len(f.When) == 0 {
+		return true
+	}
+	for _, w := range f.When {
+		if w.OS == "" || w.OS == currentOS() {
+			return true
+		}
+	}
+	return false
+}
+
+// Store loads the manifest file at Path on first use and reloads it whenever
+// the file changes.
+type Store struct {
+	Path string
+
+	mu       sync.Mutex
+	m        *Manifest
+	modTime  time.Time
+	loadedAt time.Time
+}
+
+// Status describes the manifest file of a store.
+type Status struct {
+	Path       string     `json:"path"`
+	Loaded     bool       `json:"loaded"`
+	Games      int        `json:"games"`
+	Aliases    int        `json:"aliases"`
+	ModifiedAt *time.Time `json:"modified_at,omitempty"`
+	LoadedAt   *time.Time `json:"loaded_at,omitempty"`
+}
+
+// Get returns the current manifest, reading the file again when its
+// modification time changed since the last load.
+func (s *Store) Get() (*Manifest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s does not exist", ErrNotLoaded, s.Path)
+		}
+		return nil, err
+	}
+	if s.m != nil && info.ModTime().Equal(s.modTime) {
+		return s.m, nil
+	}
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	s.m, s.modTime, s.loadedAt = m, info.ModTime(), time.Now()
+	return m, nil
+}
+
+// Status reports the store's file and, when it can be loaded, its size.
+func (s *Store) Status() (Status, error) {
+	st := Status{Path: s.Path}
+	m, err := s.Get()
+	if errors.Is(err, ErrNotLoaded) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	s.mu.Lock()
+	mod, loaded := s.modTime, s.loadedAt
+	s.mu.Unlock()
+	st.Loaded = true
+	st.Games = m.Len()
+	st.Aliases = len(m.Aliases)
+	st.ModifiedAt = &mod
+	st.LoadedAt = &loaded
+	return st, nil
+}
+
+// Replace validates the manifest read from r and atomically installs it as
+// the store's file.
+func (s *Store) Replace(r io.Reader) (*Manifest, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	m, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), ".manifest-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Rename(tmp.Name(), s.Path); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(s.Path); err == nil {
+		s.m, s.modTime, s.loadedAt = m, info.ModTime(), time.Now()
+	}
+	return m, nil
+}
@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// Audit outcomes.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// Actors of operations made outside an API request.
+const (
+	AuditActorPurger = "trash-purger"
+	AuditActorCLI    = "cli"
+)
+
+// AuditEntry records one operation that changed state: who asked for it,
+// what it touched and how it ended.
+type AuditEntry struct {
+	ID       int64     `db:"id" json:"id"`
+	Time     time.Time `db:"time" json:"time"`
+	Actor    string    `db:"actor" json:"actor,omitempty"`
+	ClientIP string    `db:"client_ip" json:"client_ip"`
+	Action   string    `db:"action" json:"action"`
+	Method   string    `db:"method" json:"method"`
+	Path     string    `db:"path" json:"path"`
+	GameID   int64     `db:"game_id" json:"game_id,omitempty"`
+	BackupID int64     `db:"backup_id" json:"backup_id,omitempty"`
+	Status   int       `db:"status" json:"status"`
+	Outcome  string    `db:"outcome" json:"outcome"`
+	// ErrorCode is the API error code of a failed operation.
+	ErrorCode  string `db:"error_code" json:"error_code,omitempty"`
+	DurationMS int64  `db:"duration_ms" json:"duration_ms"`
+	// Bytes is the size of the backup written, restored or deleted.
+	Bytes int64 `db:"bytes" json:"bytes,omitempty"`
+}
@@ -3,10 +3,45 @@ package model
 import "time"
 
 type Backup struct {
-	ID         int64     `db:"id" json:"id"`
-	GameID     int64     `db:"game_id" json:"game_id"`
-	Name       string    `db:"name" json:"name"`
-	BackupPath string    `db:"backup_path" json:"backup_path"`
-	CreatedAt  time.Time `db:"created_at" json:"created_at"`
-	SizeBytes  int64     `db:"size_bytes" json:"size_bytes"`
+	ID         int64  `db:"id" json:"id"`
+	GameID     int64  `db:"game_id" json:"game_id"`
+	Name       string `db:"name" json:"name"`
+	BackupPath string `db:"backup_path" json:"backup_path"`
+	// Profile is the save profile the backup was taken from; empty means
+	// DefaultProfile.
+	Profile   string    `db:"profile" json:"profile,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	SizeBytes int64     `db:"size_bytes" json:"size_bytes"`
+	Notes     string    `db:"notes" json:"notes,omitempty"`
+	Tags      []string  `db:"tags" json:"tags,omitempty"`
+	// Pinned backups are kept until they are unpinned: they cannot be
+	// deleted and reconcile does not prune their records.
+	Pinned bool `db:"pinned" json:"pinned,omitempty"`
+}
+
+// HasTag reports whether the backup carries tag.
+func (b *Backup) HasTag(tag string) bool {
+	for _, t := range b.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// ProfileName returns the profile the backup belongs to.
+func (b *Backup) ProfileName() string {
+	if b.Profile == "" {
+		return DefaultProfile
+	}
+	return b.Profile
+}
+
+// StoredProfile returns the value stored for a profile name: empty for
+// DefaultProfile, so backups of games without profiles stay unchanged.
+func StoredProfile(name string) string {
+	if name == DefaultProfile {
+		return ""
+	}
+	return name
 }
@@ -2,12 +2,61 @@ package model
 
 import "time"
 
+// Game types. An emulator game owns only the files matching its save patterns
+// inside a save directory shared with other ROMs.
+const (
+	GameTypeStandard = "standard"
+	GameTypeEmulator = "emulator"
+)
+
+// DefaultProfile is the save profile every game has. Backups taken before
+// profiles existed belong to it.
+const DefaultProfile = "default"
+
 type Game struct {
-	ID           int64      `db:"id" json:"id"`
-	Name         string     `db:"name" json:"name"`
-	GamePath     string     `db:"game_path" json:"game_path"`
-	BackupRoot   string     `db:"backup_root" json:"backup_root"`
+	ID         int64  `db:"id" json:"id"`
+	Name       string `db:"name" json:"name"`
+	GamePath   string `db:"game_path" json:"game_path"`
+	BackupRoot string `db:"backup_root" json:"backup_root"`
+	SteamAppID int64  `db:"steam_app_id" json:"steam_app_id,omitempty"`
+	// SteamUserID is the Steam account whose userdata <storeUserId> stands
+	// for, pinned when the game is linked to its app.
+	SteamUserID  string   `db:"steam_user_id" json:"steam_user_id,omitempty"`
+	Type         string   `db:"type" json:"type,omitempty"`
+	RomName      string   `db:"rom_name" json:"rom_name,omitempty"`
+	SavePatterns []string `db:"save_patterns" json:"save_patterns,omitempty"`
+	// Profile is the save profile currently in the game's save directory;
+	// empty means DefaultProfile. Profiles lists the other named profiles.
+	Profile  string   `db:"profile" json:"profile,omitempty"`
+	Profiles []string `db:"profiles" json:"profiles,omitempty"`
+	// Platform, Category and Favorite are free-form library metadata. Icon
+	// is the file name of the uploaded icon in the icon directory.
+	Platform     string     `db:"platform" json:"platform,omitempty"`
+	Category     string     `db:"category" json:"category,omitempty"`
+	Favorite     bool       `db:"favorite" json:"favorite,omitempty"`
+	Icon         string     `db:"icon" json:"icon,omitempty"`
 	LastBackupAt *time.Time `db:"last_backup_at" json:"last_backup_at"`
 	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
 	UpdatedAt    time.Time  `db:"updated_at" json:"updated_at"`
 }
+
+// ActiveProfile returns the name of the profile in the save directory.
+func (g *Game) ActiveProfile() string {
+	if g.Profile == "" {
+		return DefaultProfile
+	}
+	return g.Profile
+}
+
+// HasProfile reports whether name is one of the game's profiles.
+func (g *Game) HasProfile(name string) bool {
+	if name == DefaultProfile {
+		return true
+	}
+	for _, p := range g.Profiles {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// Reasons a game configuration version was recorded.
+const (
+	GameVersionInitial = "initial"
+	GameVersionCreate  = "create"
+	GameVersionUpdate  = "update"
+	GameVersionRevert  = "revert"
+)
+
+// GameConfig is the user-editable configuration of a game. It is what the
+// game history versions and what a revert puts back; runtime state such as
+// the active profile, the icon and the last backup time is not part of it.
+type GameConfig struct {
+	Name         string   `json:"name"`
+	GamePath     string   `json:"game_path"`
+	BackupRoot   string   `json:"backup_root"`
+	SteamAppID   int64    `json:"steam_app_id,omitempty"`
+	SteamUserID  string   `json:"steam_user_id,omitempty"`
+	Type         string   `json:"type,omitempty"`
+	RomName      string   `json:"rom_name,omitempty"`
+	SavePatterns []string `json:"save_patterns,omitempty"`
+	Platform     string   `json:"platform,omitempty"`
+	Category     string   `json:"category,omitempty"`
+	Favorite     bool     `json:"favorite,omitempty"`
+}
+
+// Config returns the game's configuration.
+func (g *Game) Config() GameConfig {
+	return GameConfig{
+		Name:         g.Name,
+		GamePath:     g.GamePath,
+		BackupRoot:   g.BackupRoot,
+		SteamAppID:   g.SteamAppID,
+		SteamUserID:  g.SteamUserID,
+		Type:         g.Type,
+		RomName:      g.RomName,
+		SavePatterns: g.SavePatterns,
+		Platform:     g.Platform,
+		Category:     g.Category,
+		Favorite:     g.Favorite,
+	}
+}
+
+// ApplyConfig replaces the game's configuration with cfg.
+func (g *Game) ApplyConfig(cfg GameConfig) {
+	g.Name = cfg.Name
+	g.GamePath = cfg.GamePath
+	g.BackupRoot = cfg.BackupRoot
+	g.SteamAppID = cfg.SteamAppID
+	g.SteamUserID = cfg.SteamUserID
+	g.Type = cfg.Type
+	g.RomName = cfg.RomName
+	g.SavePatterns = cfg.SavePatterns
+	g.Platform = cfg.Platform
+	g.Category = cfg.Category
+	g.Favorite = cfg.Favorite
+}
+
+// GameChange is one configuration field that differs between two versions.
+type GameChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// DiffGameConfig lists the fields that change going from one configuration
+// to the other, in a fixed order.
+func DiffGameConfig(from, to GameConfig) []GameChange {
+	var out []GameChange
+	add := func(field string, changed bool, a, b any) {
+		if changed {
+			out = append(out, GameChange{Field: field, From: a, To: b})
+		}
+	}
+	add("name", from.Name != to.Name, from.Name, to.Name)
+	add("game_path", from.GamePath != to.GamePath, from.GamePath, to.GamePath)
+	add("backup_root", from.BackupRoot != to.BackupRoot, from.BackupRoot, to.BackupRoot)
+	add("steam_app_id", from.SteamAppID != to.SteamAppID, from.SteamAppID, to.SteamAppID)
+	add("steam_user_id", from.SteamUserID != to.SteamUserID, from.SteamUserID, to.SteamUserID)
+	add("type", from.Type != to.Type, from.Type, to.Type)
+	add("rom_name", from.RomName != to.RomName, from.RomName, to.RomName)
+	add("save_patterns", !slices.Equal(from.SavePatterns, to.SavePatterns), from.SavePatterns, to.SavePatterns)
+	add("platform", from.Platform != to.Platform, from.Platform, to.Platform)
+	add("category", from.Category != to.Category, from.Category, to.Category)
+	add("favorite", from.Favorite != to.Favorite, from.Favorite, to.Favorite)
+	return out
+}
+
+// GameVersion is a game's configuration as it was after a change. Changes
+// is the diff from the previous version; it is empty for the first one.
+type GameVersion struct {
+	GameID  int64     `json:"game_id"`
+	Version int64     `json:"version"`
+	Time    time.Time `json:"time"`
+	Reason  string    `json:"reason"`
+	// RevertOf is the version a revert went back to.
+	RevertOf int64        `json:"revert_of,omitempty"`
+	Config   GameConfig   `json:"config"`
+	Changes  []GameChange `json:"changes"`
+}
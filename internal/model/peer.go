@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+type Peer struct {
+	ID         int64      `db:"id" json:"id"`
+	Name       string     `db:"name" json:"name"`
+	URL        string     `db:"url" json:"url"`
+	LastSyncAt *time.Time `db:"last_sync_at" json:"last_sync_at"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+}
+
+const (
+	SyncStatusInSync     = "in_sync"
+	SyncStatusAhead      = "ahead"
+	SyncStatusBehind     = "behind"
+	SyncStatusDiverged   = "diverged"
+	SyncStatusConflict   = "conflict"
+	SyncStatusLocalOnly  = "local_only"
+	SyncStatusRemoteOnly = "remote_only"
+)
+
+// SyncState records the outcome of the last sync of a game with a peer.
+type SyncState struct {
+	PeerID       int64     `db:"peer_id" json:"peer_id"`
+	GameID       int64     `db:"game_id" json:"game_id"`
+	RemoteGameID int64     `db:"remote_game_id" json:"remote_game_id"`
+	Status       string    `db:"status" json:"status"`
+	LastSyncAt   time.Time `db:"last_sync_at" json:"last_sync_at"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// TrashedBackup is a deleted backup waiting in the trash of its backup root
+// until it is restored or purged. Backup keeps the original record, id
+// included, so a restore puts it back unchanged.
+type TrashedBackup struct {
+	ID     int64  `db:"id" json:"id"`
+	Backup Backup `db:"backup" json:"backup"`
+	// TrashPath is where the backup folder was moved; empty when the folder
+	// was already missing at deletion.
+	TrashPath string    `db:"trash_path" json:"trash_path,omitempty"`
+	DeletedAt time.Time `db:"deleted_at" json:"deleted_at"`
+}
@@ -0,0 +1,334 @@
+// Package pathvar resolves the variables allowed in game paths, so one game
+// definition can be shared across machines and users. Paths are stored as
+// templates and resolved each time an operation touches the file system.
+//
+// Supported forms:
+//
+//	~ or ~/...           the current user's home directory
+//	$NAME, ${NAME}       environment variables
+//	%NAME%               environment variables, Windows style
+//	<home> <user> <steam>
+//	<winAppData> <xdgData> ... the placeholders of the Ludusavi manifest
+//	<root> <game> <base> <storeGameId> <storeUserId>  for Steam games
+//
+// HOME, XDG_DATA_HOME and XDG_CONFIG_HOME fall back to their usual defaults
+// when unset. Any other unknown or unset variable is an error.
+package pathvar
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"gamebk/internal/model"
+	"gamebk/internal/steam"
+)
+
+// ErrUnresolved is returned when a template references a variable that has no
+// value on this machine.
+var ErrUnresolved = errors.New("unresolved path variable")
+
+// varRe matches <name>, %NAME%, ${NAME} and $NAME.
+var varRe = regexp.MustCompile(`<[A-Za-z][A-Za-z0-9]*>|%[A-Za-z_][A-Za-z0-9_()]*%|\$\{[A-Za-z_][A-Za-z0-9_]*\}|\$[A-Za-z_][A-Za-z0-9_]*`)
+
+// Resolver expands path templates. SteamRoot overrides the detected Steam
+// installation used for <steam>.
+type Resolver struct {
+	SteamRoot string
+
+	steam *steamCache
+}
+
+// steamCache holds the Steam installation of a cached resolver.
+type steamCache struct {
+	inst *steam.Cache
+	err  error
+}
+
+// Cached returns a resolver that looks up the Steam installation and each
+// app at most once, for one request resolving many games. It is not safe for
+// concurrent use.
+func (r *Resolver) Cached() *Resolver {
+	out := &Resolver{steam: &steamCache{}}
+	if r != nil {
+		out.SteamRoot = r.SteamRoot
+	}
+	return out
+}
+
+// installVars are the Steam placeholders that need the app installed.
+var installVars = []string{"<root>", "<game>", "<base>"}
+
+// Vars holds extra placeholder values for one resolution, keyed by name
+// without the angle brackets.
+type Vars map[string]string
+
+// Resolve expands every variable in template and returns a clean path.
+func (r *Resolver) Resolve(template string) (string, error) {
+	return r.ResolveWith(template, nil)
+}
+
+// ResolveWith is Resolve with extra placeholders, which take precedence over
+// the built-in ones.
+func (r *Resolver) ResolveWith(template string, vars Vars) (string, error) {
+	p := strings.TrimSpace(template)
+	if p == "" {
+		return "", nil
+	}
+
+	tilde := p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, `~\`)
+	if tilde {
+		p = p[1:]
+	}
+
+	// one pass, so values containing $ or % are not expanded again
+	var firstErr error
+	p = varRe.ReplaceAllStringFunc(p, func(m string) string {
+		var v string
+		var err error
+		switch {
+		case m[0] == '<':
+			if val, ok := vars[m[1:len(m)-1]]; ok {
+				v = val
+				break
+			}
+			v, err = r.placeholder(m[1 : len(m)-1])
+		case m[0] == '%':
+			v, err = env(m[1 : len(m)-1])
+		case strings.HasPrefix(m, "${"):
+			v, err = env(m[2 : len(m)-1])
+		default:
+			v, err = env(m[1:])
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return v
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	if tilde {
+		home, err := homeDir()
+		if err != nil {
+			return "", err
+		}
+		p = home + p
+	}
+	return filepath.Clean(filepath.FromSlash(p)), nil
+}
+
+// ResolveGame returns a copy of g with GamePath and BackupRoot resolved. For
+// a Steam game <storeGameId> is the app id and <storeUserId> the account
+// pinned on the game. Only when the paths use <root>, <game> or <base>, or
+// <storeUserId> without a pinned account, is the app looked up in the Steam
+// installation, so games with plain paths keep working once uninstalled.
+func (r *Resolver) ResolveGame(g *model.Game) (*model.Game, error) {
+	var vars Vars
+	if g.SteamAppID != 0 {
+		vars = Vars{"storeGameId": strconv.FormatInt(g.SteamAppID, 10)}
+		if g.SteamUserID != "" {
+			vars["storeUserId"] = g.SteamUserID
+		}
+		if needsInstall(g.GamePath, vars) || needsInstall(g.BackupRoot, vars) {
+			app, err := r.SteamApp(g.SteamAppID)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrUnresolved, err)
+			}
+			for k, v := range AppVars(app) {
+				if _, ok := vars[k]; !ok {
+					vars[k] = v
+				}
+			}
+			if g.SteamUserID == "" && len(app.UserData) > 1 {
+				// do not guess between accounts for an unpinned game
+				delete(vars, "storeUserId")
+			}
+		}
+	}
+	out := *g
+	var err error
+	if out.GamePath, err = r.ResolveWith(g.GamePath, vars); err != nil {
+		return nil, fmt.Errorf("game_path: %w", err)
+	}
+	if out.BackupRoot, err = r.ResolveWith(g.BackupRoot, vars); err != nil {
+		return nil, fmt.Errorf("backup_root: %w", err)
+	}
+	return &out, nil
+}
+
+// needsInstall reports whether template uses a Steam placeholder that vars
+// cannot provide without the app's install location.
+func needsInstall(template string, vars Vars) bool {
+	for _, v := range installVars {
+		if strings.Contains(template, v) {
+			return true
+		}
+	}
+	_, pinned := vars["storeUserId"]
+	return !pinned && strings.Contains(template, "<storeUserId>")
+}
+
+// SteamApp looks up an installed Steam app in the Steam installation.
+func (r *Resolver) SteamApp(appID int64) (*steam.App, error) {
+	if r != nil && r.steam != nil {
+		if r.steam.inst == nil && r.steam.err == nil {
+			root, err := r.Steam()
+			r.steam.inst, r.steam.err = &steam.Cache{Installation: steam.Installation{Root: root}}, err
+		}
+		if r.steam.err != nil {
+			return nil, r.steam.err
+		}
+		return r.steam.inst.App(appID)
+	}
+	root, err := r.Steam()
+	if err != nil {
+		return nil, err
+	}
+	return steam.Installation{Root: root}.App(appID)
+}
+
+// AppVars returns the placeholders describing app.
+func AppVars(app *steam.App) Vars {
+	vars := Vars{
+		"root":        app.LibraryPath,
+		"game":        app.InstallDir,
+		"base":        app.InstallPath,
+		"storeGameId": strconv.FormatInt(app.ID, 10),
+	}
+	if len(app.UserData) > 0 {
+		vars["storeUserId"] = app.UserData[0].UserID
+	}
+	return vars
+}
+
+// Steam returns the Steam installation directory: SteamRoot when set,
+// otherwise the first default location that exists on this platform.
+func (r *Resolver) Steam() (string, error) {
+	if r != nil && r.SteamRoot != "" {
+		if strings.Contains(r.SteamRoot, "<steam>") {
+			return "", fmt.Errorf("%w: Steam root must not reference <steam>", ErrUnresolved)
+		}
+		return r.Resolve(r.SteamRoot)
+	}
+	for _, dir := range steamCandidates() {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("%w: <steam>: Steam installation not found, set GAMEBK_STEAM_ROOT", ErrUnresolved)
+}
+
+func (r *Resolver) placeholder(name string) (string, error) {
+	switch name {
+	case "home":
+		return homeDir()
+	case "user", "osUserName":
+		return userName()
+	case "steam":
+		return r.Steam()
+	case "winAppData":
+		return env("APPDATA")
+	case "winLocalAppData":
+		return env("LOCALAPPDATA")
+	case "winLocalAppDataLow":
+		local, err := env("LOCALAPPDATA")
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(filepath.Dir(local), "LocalLow"), nil
+	case "winDocuments":
+		home, err := homeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, "Documents"), nil
+	case "winPublic":
+		return env("PUBLIC")
+	case "winProgramData":
+		return env("PROGRAMDATA")
+	case "winDir":
+		return env("WINDIR")
+	case "xdgData":
+		return env("XDG_DATA_HOME")
+	case "xdgConfig":
+		return env("XDG_CONFIG_HOME")
+	}
+	return "", fmt.Errorf("%w: unknown placeholder <%s>", ErrUnresolved, name)
+}
+
+// env looks up an environment variable, applying the defaults for HOME and
+// the XDG base directories.
+func env(name string) (string, error) {
+	if v := os.Getenv(name); v != "" {
+		return v, nil
+	}
+	switch name {
+	case "HOME", "USERPROFILE":
+		return homeDir()
+	case "XDG_DATA_HOME":
+		return underHome(".local", "share")
+	case "XDG_CONFIG_HOME":
+		return underHome(".config")
+	}
+	return "", fmt.Errorf("%w: environment variable %s is not set", ErrUnresolved, name)
+}
+
+func homeDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("%w: home directory: %v", ErrUnresolved, err)
+	}
+	return home, nil
+}
+
+func underHome(elem ...string) (string, error) {
+	home, err := homeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(append([]string{home}, elem...)...), nil
+}
+
+func userName() (string, error) {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		name := u.Username
+		// Windows reports DOMAIN\user
+		if i := strings.LastIndex(name, `\`); i >= 0 {
+			name = name[i+1:]
+		}
+		return name, nil
+	}
+	for _, key := range []string{"USER", "USERNAME"} {
+		if v := os.Getenv(key); v != "" {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("%w: <user>: current user unknown", ErrUnresolved)
+}
+
+func steamCandidates() []string {
+	home, _ := os.UserHomeDir()
+	switch runtime.GOOS {
+	case "windows":
+		out := []string{}
+		if pf := os.Getenv("ProgramFiles(x86)"); pf != "" {
+			out = append(out, filepath.Join(pf, "Steam"))
+		}
+		return append(out, `C:\Program Files (x86)\Steam`, `C:\Program Files\Steam`)
+	case "darwin":
+		return []string{filepath.Join(home, "Library", "Application Support", "Steam")}
+	default:
+		return []string{
+			filepath.Join(home, ".steam", "steam"),
+			filepath.Join(home, ".local", "share", "Steam"),
+			filepath.Join(home, ".var", "app", "com.valvesoftware.Steam", ".local", "share", "Steam"),
+		}
+	}
+}
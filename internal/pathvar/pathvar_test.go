@@ -0,0 +1,100 @@
+package pathvar
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveWith(t *testing.T) {
+	home := filepath.FromSlash("/home/tester")
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("GAMEBK_TEST_DIR", "/data/saves")
+	t.Setenv("GAMEBK_TEST_DOLLAR", "$GAMEBK_TEST_DIR%x%")
+	t.Setenv("XDG_DATA_HOME", "")
+
+	r := &Resolver{SteamRoot: "/opt/steam"}
+	tests := []struct {
+		name     string
+		template string
+		vars     Vars
+		want     string
+	}{
+		{name: "empty", template: "  ", want: ""},
+		{name: "plain", template: "/games/save", want: "/games/save"},
+		{name: "clean", template: "/games//a/../save/", want: "/games/save"},
+		{name: "tilde", template: "~", want: "/home/tester"},
+		{name: "tilde slash", template: "~/saves", want: "/home/tester/saves"},
+		{name: "tilde inside", template: "/x/~/y", want: "/x/~/y"},
+		{name: "dollar", template: "$GAMEBK_TEST_DIR/a", want: "/data/saves/a"},
+		{name: "braces", template: "${GAMEBK_TEST_DIR}b", want: "/data/savesb"},
+		{name: "percent", template: "%GAMEBK_TEST_DIR%/c", want: "/data/saves/c"},
+		{name: "expanded once", template: "$GAMEBK_TEST_DOLLAR", want: "$GAMEBK_TEST_DIR%x%"},
+		{name: "home placeholder", template: "<home>/d", want: "/home/tester/d"},
+		{name: "xdg default", template: "<xdgData>/e", want: "/home/tester/.local/share/e"},
+		{name: "steam root", template: "<steam>/userdata", want: "/opt/steam/userdata"},
+		{
+			name:     "vars",
+			template: "<base>/<storeGameId>/<storeUserId>",
+			vars:     Vars{"base": "/lib/common/Game", "storeGameId": "620", "storeUserId": "42"},
+			want:     "/lib/common/Game/620/42",
+		},
+		{
+			name:     "vars override built-ins",
+			template: "<home>/f",
+			vars:     Vars{"home": "/elsewhere"},
+			want:     "/elsewhere/f",
+		},
+		{
+			name:     "var values not expanded",
+			template: "<game>",
+			vars:     Vars{"game": "$HOME"},
+			want:     "$HOME",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.ResolveWith(tt.template, tt.vars)
+			if err != nil {
+				t.Fatalf("ResolveWith(%q): %v", tt.template, err)
+			}
+			want := tt.want
+			if want != "" {
+				want = filepath.Clean(filepath.FromSlash(want))
+			}
+			if got != want {
+				t.Errorf("ResolveWith(%q) = %q, want %q", tt.template, got, want)
+			}
+		})
+	}
+}
+
+func TestResolveWithUnresolved(t *testing.T) {
+	t.Setenv("GAMEBK_TEST_UNSET", "")
+	tests := []struct {
+		name     string
+		template string
+		vars     Vars
+	}{
+		{name: "unset env", template: "$GAMEBK_TEST_UNSET/a"},
+		{name: "unset percent", template: "%GAMEBK_TEST_UNSET%"},
+		{name: "unknown placeholder", template: "<nope>/a"},
+		{name: "install var without app", template: "<base>/save", vars: Vars{"storeGameId": "620"}},
+	}
+	r := &Resolver{SteamRoot: "/opt/steam"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := r.ResolveWith(tt.template, tt.vars); !errors.Is(err, ErrUnresolved) {
+				t.Errorf("ResolveWith(%q) err = %v, want ErrUnresolved", tt.template, err)
+			}
+		})
+	}
+}
+
+func TestSteamRootSelfReference(t *testing.T) {
+	r := &Resolver{SteamRoot: "<steam>/x"}
+	if _, err := r.Resolve("<steam>"); !errors.Is(err, ErrUnresolved) {
+		t.Errorf("err = %v, want ErrUnresolved", err)
+	}
+}
@@ -0,0 +1,235 @@
+package peer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gamebk/internal/model"
+)
+
+// Requests to peers name the actor "peer" in the peer's audit log.
+const (
+	actorHeader = "X-Gamebk-Actor"
+	actorPeer   = "peer"
+)
+
+// Client talks to the REST API of another gamebk instance.
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+// RemoteError is returned when the peer answers with an error response.
+type RemoteError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("peer responded %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		HTTP:    &http.Client{Timeout: 30 * time.Minute},
+	}
+}
+
+func (c *Client) ListGames(ctx context.Context) ([]model.Game, error) {
+	var out []model.Game
+	if err := c.doJSON(ctx, http.MethodGet, "/api/v1/games", nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *Client) CreateGame(ctx context.Context, g *model.Game) (*model.Game, error) {
+	body := map[string]any{
+		"name":        g.Name,
+		"game_path":   g.GamePath,
+		"backup_root": g.BackupRoot,
+	}
+	if g.SteamAppID != 0 {
+		body["steam_app_id"] = g.SteamAppID
+	}
+	if g.SteamUserID != "" {
+		body["steam_user_id"] = g.SteamUserID
+	}
+	if g.Platform != "" {
+		body["platform"] = g.Platform
+	}
+	if g.Category != "" {
+		body["category"] = g.Category
+	}
+	if g.Type != "" {
+		body["type"] = g.Type
+		body["rom_name"] = g.RomName
+		body["save_patterns"] = g.SavePatterns
+	}
+	var out model.Game
+	if err := c.doJSON(ctx, http.MethodPost, "/api/v1/games", body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) ListBackups(ctx context.Context, gameID int64) ([]model.Backup, error) {
+	var out []model.Backup
+	path := fmt.Sprintf("/api/v1/games/%d/backups", gameID)
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DownloadBackup streams a remote backup as a tar.gz bundle.
+// The caller must close the returned reader.
+func (c *Client) DownloadBackup(ctx context.Context, gameID, backupID int64) (io.ReadCloser, error) {
+	path := fmt.Sprintf("/api/v1/games/%d/backups/%d/download?format=tar.gz", gameID, backupID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, decodeError(resp)
+	}
+	return resp.Body, nil
+}
+
+// UploadBackup sends a bundle to the peer and registers it as a backup of the
+// remote game; the peer takes name and creation time from the manifest and
+// pins it only when pinned is set.
+func (c *Client) UploadBackup(ctx context.Context, gameID int64, bundle io.Reader, pinned bool) (*model.Backup, error) {
+	path := fmt.Sprintf("/api/v1/games/%d/backups/upload", gameID)
+	if pinned {
+		path += "?pinned=true"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, bundle)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set(actorHeader, actorPeer)
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var out model.Backup
+	if err := decodeData(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DownloadArchive fetches a backup as a plain tar.gz from a peer that
+// predates bundles.
+func (c *Client) DownloadArchive(ctx context.Context, gameID, backupID int64) (io.ReadCloser, error) {
+	path := fmt.Sprintf("/api/v1/games/%d/backups/%d/archive", gameID, backupID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, decodeError(resp)
+	}
+	return resp.Body, nil
+}
+
+// UploadArchive sends a plain tar.gz to a peer that predates bundles, keeping
+// the backup's name and creation time.
+func (c *Client) UploadArchive(ctx context.Context, gameID int64, name string, createdAt time.Time, archive io.Reader) (*model.Backup, error) {
+	q := url.Values{}
+	q.Set("name", name)
+	q.Set("created_at", createdAt.UTC().Format(time.RFC3339Nano))
+	path := fmt.Sprintf("/api/v1/games/%d/backups/archive?%s", gameID, q.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, archive)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set(actorHeader, actorPeer)
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var out model.Backup
+	if err := decodeData(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// missingRoute reports whether err is the bare 404 of a route the peer does
+// not have, as opposed to a not_found error from a route it does have.
+func missingRoute(err error) bool {
+	var re *RemoteError
+	return errors.As(err, &re) && re.Status == http.StatusNotFound && re.Code == ""
+}
+
+func (c *Client) doJSON(ctx context.Context, method, path string, body, dst interface{}) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set(actorHeader, actorPeer)
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return decodeData(resp, dst)
+}
+
+func decodeData(resp *http.Response, dst interface{}) error {
+	if resp.StatusCode >= 300 {
+		return decodeError(resp)
+	}
+	envelope := struct {
+		Data interface{} `json:"data"`
+	}{Data: dst}
+	return json.NewDecoder(resp.Body).Decode(&envelope)
+}
+
+func decodeError(resp *http.Response) error {
+	var body struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	return &RemoteError{Status: resp.StatusCode, Code: body.Code, Message: body.Message}
+}
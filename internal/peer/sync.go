@@ -0,0 +1,523 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gamebk/internal/backup"
+	"gamebk/internal/job"
+	"gamebk/internal/library"
+	"gamebk/internal/model"
+	"gamebk/internal/pathvar"
+	"gamebk/internal/repository"
+)
+
+const (
+	DirectionPush = "push"
+	DirectionPull = "pull"
+	DirectionBoth = "both"
+)
+
+// Conflict resolution strategies. With no strategy, games in conflict are
+// reported and left untouched.
+const (
+	StrategyKeepBoth     = "keep_both"
+	StrategyPreferLocal  = "prefer_local"
+	StrategyPreferRemote = "prefer_remote"
+)
+
+var ErrInvalidOptions = errors.New("invalid sync options")
+
+type Options struct {
+	GameIDs   []int64
+	Direction string
+	Strategy  string
+}
+
+// GameSync describes the sync state of one game, matched by name between the
+// local instance and the peer.
+type GameSync struct {
+	Name         string     `json:"name"`
+	GameID       int64      `json:"game_id,omitempty"`
+	RemoteGameID int64      `json:"remote_game_id,omitempty"`
+	Status       string     `json:"status"`
+	LastSyncAt   *time.Time `json:"last_sync_at,omitempty"`
+	LocalOnly    []string   `json:"local_only,omitempty"`
+	RemoteOnly   []string   `json:"remote_only,omitempty"`
+	Pushed       []string   `json:"pushed,omitempty"`
+	Pulled       []string   `json:"pulled,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+type Syncer struct {
+	Repo  *repository.Repository
+	Jobs  *job.Tracker
+	Paths *pathvar.Resolver
+}
+
+type pair struct {
+	local  *model.Game
+	remote *model.Game
+	state  *model.SyncState
+	push   []model.Backup
+	pull   []model.Backup
+}
+
+// Status compares every game with the peer without transferring anything.
+func (s *Syncer) Status(ctx context.Context, p *model.Peer) ([]GameSync, error) {
+	pairs, err := s.pairs(ctx, NewClient(p.URL), p, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]GameSync, 0, len(pairs))
+	for _, pr := range pairs {
+		out = append(out, pr.report())
+	}
+	return out, nil
+}
+
+// Sync pushes and pulls game definitions and backups with the peer.
+func (s *Syncer) Sync(ctx context.Context, p *model.Peer, opts Options) ([]GameSync, error) {
+	if opts.Direction == "" {
+		opts.Direction = DirectionBoth
+	}
+	switch opts.Direction {
+	case DirectionPush, DirectionPull, DirectionBoth:
+	default:
+		return nil, fmt.Errorf("%w: unknown direction %q", ErrInvalidOptions, opts.Direction)
+	}
+	switch opts.Strategy {
+	case "", StrategyKeepBoth, StrategyPreferLocal, StrategyPreferRemote:
+	default:
+		return nil, fmt.Errorf("%w: unknown strategy %q", ErrInvalidOptions, opts.Strategy)
+	}
+
+	client := NewClient(p.URL)
+	pairs, err := s.pairs(ctx, client, p, opts.GameIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]GameSync, 0, len(pairs))
+	for _, pr := range pairs {
+		res := pr.report()
+		if err := s.syncPair(ctx, client, p, pr, opts, &res); err != nil {
+			res.Error = err.Error()
+		}
+		out = append(out, res)
+	}
+	if err := s.Repo.Peers.UpdateLastSyncAt(ctx, p.ID, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *Syncer) syncPair(ctx context.Context, client *Client, p *model.Peer, pr *pair, opts Options, res *GameSync) error {
+	push := opts.Direction != DirectionPull
+	pull := opts.Direction != DirectionPush
+	if pr.status() == model.SyncStatusConflict {
+		switch opts.Strategy {
+		case StrategyKeepBoth:
+		case StrategyPreferLocal:
+			pull = false
+		case StrategyPreferRemote:
+			push = false
+		default:
+			return s.saveState(ctx, p, pr)
+		}
+	}
+
+	if pr.remote == nil {
+		if !push {
+			return nil
+		}
+		created, err := client.CreateGame(ctx, pr.local)
+		if err != nil {
+			return err
+		}
+		pr.remote = created
+		res.RemoteGameID = created.ID
+	}
+	if pr.local != nil {
+		done, current, ok := s.Jobs.Start(pr.local.ID, job.KindSync)
+		if !ok {
+			return fmt.Errorf("%s job is running for this game", current.Kind)
+		}
+		defer done()
+	}
+	if pr.local == nil {
+		if !pull {
+			return nil
+		}
+		g := &model.Game{
+			Name:         pr.remote.Name,
+			GamePath:     pr.remote.GamePath,
+			BackupRoot:   pr.remote.BackupRoot,
+			SteamAppID:   pr.remote.SteamAppID,
+			SteamUserID:  pr.remote.SteamUserID,
+			Type:         pr.remote.Type,
+			RomName:      pr.remote.RomName,
+			SavePatterns: pr.remote.SavePatterns,
+			Profiles:     pr.remote.Profiles,
+			Platform:     pr.remote.Platform,
+			Category:     pr.remote.Category,
+		}
+		if err := s.checkPulledGame(ctx, g); err != nil {
+			return err
+		}
+		if err := s.Repo.Games.Create(ctx, g); err != nil {
+			return err
+		}
+		pr.local = g
+		res.GameID = g.ID
+	}
+
+	if push {
+		for len(pr.push) > 0 {
+			b := pr.push[0]
+			if err := pushBackup(ctx, client, pr.local, pr.remote.ID, b); err != nil {
+				return err
+			}
+			res.Pushed = append(res.Pushed, b.Name)
+			pr.push = pr.push[1:]
+		}
+	}
+	if pull {
+		for len(pr.pull) > 0 {
+			b := pr.pull[0]
+			if err := s.pullBackup(ctx, client, pr, b); err != nil {
+				return err
+			}
+			res.Pulled = append(res.Pulled, b.Name)
+			pr.pull = pr.pull[1:]
+		}
+	}
+
+	if len(pr.push) == 0 && len(pr.pull) == 0 {
+		t := time.Now().UTC()
+		pr.state = &model.SyncState{LastSyncAt: t}
+		res.LastSyncAt = &t
+	}
+	res.Status = pr.status()
+	res.LocalOnly = backupNames(pr.push)
+	res.RemoteOnly = backupNames(pr.pull)
+	return s.saveState(ctx, p, pr)
+}
+
+func (s *Syncer) saveState(ctx context.Context, p *model.Peer, pr *pair) error {
+	if pr.local == nil {
+		return nil
+	}
+	st := model.SyncState{
+		PeerID: p.ID,
+		GameID: pr.local.ID,
+		Status: pr.status(),
+	}
+	if pr.remote != nil {
+		st.RemoteGameID = pr.remote.ID
+	}
+	if pr.state != nil {
+		st.LastSyncAt = pr.state.LastSyncAt
+	}
+	return s.Repo.Peers.PutSyncState(ctx, &st)
+}
+
+// checkPulledGame runs the checks the API applies to a new game on a game
+// definition pulled from the peer: its type and metadata, its resolved paths
+// and, for an emulator game, the saves of the emulator games sharing its save
+// directory.
+func (s *Syncer) checkPulledGame(ctx context.Context, g *model.Game) error {
+	if err := library.ValidateGame(g); err != nil {
+		return fmt.Errorf("remote game %q: %w", g.Name, err)
+	}
+	resolved, err := s.Paths.ResolveGame(g)
+	if err != nil {
+		return err
+	}
+	if err := backup.ValidateGamePaths(resolved.GamePath, resolved.BackupRoot); err != nil {
+		return err
+	}
+	games, err := s.Repo.Games.List(ctx)
+	if err != nil {
+		return err
+	}
+	other, err := library.ScopeConflict(s.Paths, resolved, games)
+	if err != nil {
+		return err
+	}
+	if other != nil {
+		return fmt.Errorf("remote game %q: its saves overlap those of %q in %s", g.Name, other.Name, resolved.GamePath)
+	}
+	return nil
+}
+
+// pushBackup sends b to the peer as a bundle, falling back to a plain tar.gz
+// for peers that predate bundles; those keep only the name and creation time.
+func pushBackup(ctx context.Context, client *Client, g *model.Game, remoteGameID int64, b model.Backup) error {
+	m := &backup.BundleManifest{
+		GameName:   g.Name,
+		BackupName: b.Name,
+		Profile:    b.Profile,
+		Notes:      b.Notes,
+		Tags:       b.Tags,
+		Pinned:     b.Pinned,
+		CreatedAt:  b.CreatedAt,
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(backup.WriteBundle(pw, backup.FormatTarGz, b.BackupPath, m))
+	}()
+	_, err := client.UploadBackup(ctx, remoteGameID, pr, b.Pinned)
+	_ = pr.Close()
+	if !missingRoute(err) {
+		return err
+	}
+
+	pr, pw = io.Pipe()
+	go func() {
+		pw.CloseWithError(backup.WriteTarGz(pw, b.BackupPath))
+	}()
+	_, err = client.UploadArchive(ctx, remoteGameID, b.Name, b.CreatedAt, pr)
+	_ = pr.Close()
+	return err
+}
+
+func (s *Syncer) pullBackup(ctx context.Context, client *Client, pr *pair, b model.Backup) error {
+	game, err := s.Paths.ResolveGame(pr.local)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(game.BackupRoot, 0o755); err != nil {
+		return err
+	}
+	profile := b.ProfileName()
+	if !pr.local.HasProfile(profile) {
+		if err := backup.ValidateName(profile); err != nil {
+			return fmt.Errorf("backup %q: invalid profile: %w", b.Name, err)
+		}
+	}
+	backupPath, size, err := fetchBackup(ctx, client, pr.remote.ID, b, game.BackupRoot)
+	if err != nil {
+		return err
+	}
+	if !pr.local.HasProfile(profile) {
+		if err := s.Repo.Games.AddProfile(ctx, pr.local.ID, profile); err != nil {
+			_ = os.RemoveAll(backupPath)
+			return err
+		}
+		pr.local.Profiles = append(pr.local.Profiles, profile)
+	}
+	local := &model.Backup{
+		GameID:     pr.local.ID,
+		Name:       b.Name,
+		BackupPath: backupPath,
+		Profile:    model.StoredProfile(profile),
+		Notes:      b.Notes,
+		Tags:       b.Tags,
+		Pinned:     b.Pinned,
+		SizeBytes:  size,
+		CreatedAt:  b.CreatedAt,
+	}
+	if err := s.Repo.Backups.Create(ctx, local); err != nil {
+		return err
+	}
+	if pr.local.LastBackupAt == nil || b.CreatedAt.After(*pr.local.LastBackupAt) {
+		if err := s.Repo.Games.UpdateLastBackupAt(ctx, pr.local.ID, b.CreatedAt); err != nil {
+			return err
+		}
+		pr.local.LastBackupAt = &b.CreatedAt
+	}
+	return nil
+}
+
+// fetchBackup downloads the remote backup b into backupRoot under its name as
+// a verified bundle, or as a plain tar.gz from peers that predate bundles.
+// Returns the installed path and its size.
+func fetchBackup(ctx context.Context, client *Client, remoteGameID int64, b model.Backup, backupRoot string) (string, int64, error) {
+	body, err := client.DownloadBackup(ctx, remoteGameID, b.ID)
+	if missingRoute(err) {
+		if err := backup.ValidateName(b.Name); err != nil {
+			return "", 0, err
+		}
+		body, err = client.DownloadArchive(ctx, remoteGameID, b.ID)
+		if err != nil {
+			return "", 0, err
+		}
+		defer func() { _ = body.Close() }()
+		backupPath := filepath.Join(backupRoot, b.Name)
+		size, err := backup.ExtractTarGz(body, backupPath)
+		return backupPath, size, err
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = body.Close() }()
+
+	tmp, err := os.CreateTemp(backupRoot, ".download-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	_, err = io.Copy(tmp, body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	m, backupPath, err := backup.InstallBundle(tmp.Name(), backupRoot, b.Name)
+	if err != nil {
+		return "", 0, err
+	}
+	return backupPath, m.SizeBytes, nil
+}
+
+// pairs matches local and remote games by name and computes which backups
+// exist on only one side. When gameIDs is set only those local games are used.
+func (s *Syncer) pairs(ctx context.Context, client *Client, p *model.Peer, gameIDs []int64) ([]*pair, error) {
+	locals, err := s.Repo.Games.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	remotes, err := client.ListGames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter map[int64]bool
+	if len(gameIDs) > 0 {
+		filter = make(map[int64]bool, len(gameIDs))
+		for _, id := range gameIDs {
+			filter[id] = true
+		}
+	}
+
+	byName := make(map[string]*pair)
+	var out []*pair
+	for i := range locals {
+		g := &locals[i]
+		if filter != nil && !filter[g.ID] {
+			continue
+		}
+		pr := &pair{local: g}
+		byName[g.Name] = pr
+		out = append(out, pr)
+	}
+	for i := range remotes {
+		g := &remotes[i]
+		if pr, ok := byName[g.Name]; ok {
+			pr.remote = g
+			continue
+		}
+		if filter != nil {
+			continue
+		}
+		pr := &pair{remote: g}
+		byName[g.Name] = pr
+		out = append(out, pr)
+	}
+
+	for _, pr := range out {
+		var localBackups, remoteBackups []model.Backup
+		if pr.local != nil {
+			st, err := s.Repo.Peers.GetSyncState(ctx, p.ID, pr.local.ID)
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return nil, err
+			}
+			pr.state = st
+			if localBackups, err = s.Repo.Backups.ListByGameID(ctx, pr.local.ID); err != nil {
+				return nil, err
+			}
+		}
+		if pr.remote != nil {
+			if remoteBackups, err = client.ListBackups(ctx, pr.remote.ID); err != nil {
+				return nil, err
+			}
+		}
+		pr.push = missing(localBackups, remoteBackups)
+		pr.pull = missing(remoteBackups, localBackups)
+	}
+	return out, nil
+}
+
+func (pr *pair) status() string {
+	switch {
+	case pr.remote == nil:
+		return model.SyncStatusLocalOnly
+	case pr.local == nil:
+		return model.SyncStatusRemoteOnly
+	case len(pr.push) == 0 && len(pr.pull) == 0:
+		return model.SyncStatusInSync
+	case len(pr.pull) == 0:
+		return model.SyncStatusAhead
+	case len(pr.push) == 0:
+		return model.SyncStatusBehind
+	}
+
+	var since time.Time
+	if pr.state != nil {
+		since = pr.state.LastSyncAt
+	}
+	if createdAfter(pr.push, since) && createdAfter(pr.pull, since) {
+		return model.SyncStatusConflict
+	}
+	return model.SyncStatusDiverged
+}
+
+func (pr *pair) report() GameSync {
+	res := GameSync{
+		Status:     pr.status(),
+		LocalOnly:  backupNames(pr.push),
+		RemoteOnly: backupNames(pr.pull),
+	}
+	if pr.local != nil {
+		res.Name = pr.local.Name
+		res.GameID = pr.local.ID
+	}
+	if pr.remote != nil {
+		res.Name = pr.remote.Name
+		res.RemoteGameID = pr.remote.ID
+	}
+	if pr.state != nil && !pr.state.LastSyncAt.IsZero() {
+		t := pr.state.LastSyncAt
+		res.LastSyncAt = &t
+	}
+	return res
+}
+
+// missing returns the backups in a whose name does not appear in b.
+func missing(a, b []model.Backup) []model.Backup {
+	names := make(map[string]bool, len(b))
+	for _, x := range b {
+		names[x.Name] = true
+	}
+	var out []model.Backup
+	for _, x := range a {
+		if !names[x.Name] {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+func createdAfter(list []model.Backup, t time.Time) bool {
+	for _, b := range list {
+		if b.CreatedAt.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func backupNames(list []model.Backup) []string {
+	out := make([]string, 0, len(list))
+	for _, b := range list {
+		out = append(out, b.Name)
+	}
+	return out
+}
@@ -0,0 +1,268 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gamebk/internal/backup"
+	"gamebk/internal/job"
+	"gamebk/internal/model"
+	"gamebk/internal/pathvar"
+	"gamebk/internal/repository"
+)
+
+type Options struct {
+	// GameID limits the scan to one game when non-zero.
+	GameID int64
+	// Prune deletes records whose backup folder is missing, except pinned
+	// ones.
+	Prune bool
+	// Adopt registers untracked folders under the backup root as backups.
+	// Folders under a root shared by several games are never adopted.
+	Adopt bool
+}
+
+// Untracked is a folder under a backup root with no backup record. A folder
+// inside a subdirectory named after one of the game's profiles belongs to
+// that profile.
+type Untracked struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	Profile   string    `json:"profile,omitempty"`
+	SizeBytes int64     `json:"size_bytes"`
+	ModTime   time.Time `json:"mod_time"`
+}
+
+type GameReport struct {
+	GameID     int64          `json:"game_id"`
+	GameName   string         `json:"game_name"`
+	BackupRoot string         `json:"backup_root"`
+	Missing    []model.Backup `json:"missing"`
+	Untracked  []Untracked    `json:"untracked"`
+	Pruned     []int64        `json:"pruned,omitempty"`
+	Adopted    []model.Backup `json:"adopted,omitempty"`
+	// SharedWith lists the other games using the same backup root; their
+	// untracked folders cannot be told apart, so none are adopted.
+	SharedWith []int64 `json:"shared_with,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Scanner compares backup records with the folders under each BackupRoot.
+type Scanner struct {
+	Repo *repository.Repository
+	// Jobs is optional; when set, games with a running job are skipped.
+	Jobs *job.Tracker
+	// Paths resolves the variables in BackupRoot.
+	Paths *pathvar.Resolver
+	// Actor is recorded in the audit log for each pruned or adopted record.
+	Actor string
+}
+
+func (s *Scanner) Scan(ctx context.Context, opts Options) ([]GameReport, error) {
+	var games []model.Game
+	if opts.GameID != 0 {
+		g, err := s.Repo.Games.GetByID(ctx, opts.GameID)
+		if err != nil {
+			return nil, err
+		}
+		games = []model.Game{*g}
+	} else {
+		list, err := s.Repo.Games.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		games = list
+	}
+
+	// folders tracked by any game, so games sharing a backup root do not
+	// report each other's backups as untracked
+	tracked := make(map[string]bool)
+	all, err := s.Repo.Games.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range all {
+		backups, err := s.Repo.Backups.ListByGameID(ctx, g.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range backups {
+			tracked[filepath.Clean(b.BackupPath)] = true
+		}
+	}
+
+	paths := s.Paths.Cached()
+	roots := make(map[string][]int64)
+	for i := range all {
+		if g, err := paths.ResolveGame(&all[i]); err == nil {
+			root := filepath.Clean(g.BackupRoot)
+			roots[root] = append(roots[root], g.ID)
+		}
+	}
+
+	out := make([]GameReport, 0, len(games))
+	for i := range games {
+		rep := GameReport{
+			GameID:     games[i].ID,
+			GameName:   games[i].Name,
+			BackupRoot: games[i].BackupRoot,
+			Missing:    []model.Backup{},
+			Untracked:  []Untracked{},
+		}
+		g, err := paths.ResolveGame(&games[i])
+		if err != nil {
+			rep.Error = err.Error()
+			out = append(out, rep)
+			continue
+		}
+		rep.BackupRoot = g.BackupRoot
+		for _, id := range roots[filepath.Clean(g.BackupRoot)] {
+			if id != g.ID {
+				rep.SharedWith = append(rep.SharedWith, id)
+			}
+		}
+		if err := s.scanGame(ctx, g, tracked, opts, &rep); err != nil {
+			rep.Error = err.Error()
+		}
+		out = append(out, rep)
+	}
+	return out, nil
+}
+
+func (s *Scanner) scanGame(ctx context.Context, g *model.Game, tracked map[string]bool, opts Options, rep *GameReport) error {
+	if s.Jobs != nil && (opts.Prune || opts.Adopt) {
+		done, current, ok := s.Jobs.Start(g.ID, job.KindReconcile)
+		if !ok {
+			return errors.New(current.Kind + " job is running for this game")
+		}
+		defer done()
+	}
+
+	backups, err := s.Repo.Backups.ListByGameID(ctx, g.ID)
+	if err != nil {
+		return err
+	}
+	for _, b := range backups {
+		if _, err := os.Stat(b.BackupPath); errors.Is(err, os.ErrNotExist) {
+			rep.Missing = append(rep.Missing, b)
+		}
+	}
+
+	if err := s.findUntracked(g, g.BackupRoot, "", tracked, rep); err != nil {
+		return err
+	}
+
+	if opts.Prune {
+		for _, b := range rep.Missing {
+			if b.Pinned {
+				continue
+			}
+			if err := s.Repo.Backups.DeleteByID(ctx, b.ID); err != nil {
+				s.audit(ctx, "backup.prune", &b, "db_error")
+				return err
+			}
+			s.audit(ctx, "backup.prune", &b, "")
+			rep.Pruned = append(rep.Pruned, b.ID)
+		}
+	}
+	if opts.Adopt && len(rep.SharedWith) == 0 {
+		names := make(map[string]bool, len(backups))
+		for _, b := range backups {
+			names[b.Name] = true
+		}
+		for _, u := range rep.Untracked {
+			// a name taken in another profile stays untracked
+			if names[u.Name] || backup.ValidateName(u.Name) != nil {
+				continue
+			}
+			names[u.Name] = true
+			b := &model.Backup{
+				GameID:     g.ID,
+				Name:       u.Name,
+				BackupPath: u.Path,
+				Profile:    u.Profile,
+				SizeBytes:  u.SizeBytes,
+				CreatedAt:  u.ModTime,
+			}
+			if err := s.Repo.Backups.Create(ctx, b); err != nil {
+				s.audit(ctx, "backup.adopt", b, "db_error")
+				return err
+			}
+			s.audit(ctx, "backup.adopt", b, "")
+			tracked[filepath.Clean(u.Path)] = true
+			rep.Adopted = append(rep.Adopted, *b)
+		}
+	}
+	if opts.Prune || opts.Adopt {
+		return s.refreshLastBackupAt(ctx, g.ID)
+	}
+	return nil
+}
+
+// findUntracked adds the untracked folders of dir to rep. At the backup root a
+// folder named after one of the game's profiles is searched in turn, its
+// folders belonging to that profile.
+func (s *Scanner) findUntracked(g *model.Game, dir, profile string, tracked map[string]bool, rep *GameReport) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for _, e := range entries {
+		// dot folders are gamebk's own (the trash, staging); backup names
+		// never start with a dot
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if tracked[filepath.Clean(path)] {
+			continue
+		}
+		if profile == "" && e.Name() != model.DefaultProfile && g.HasProfile(e.Name()) {
+			if err := s.findUntracked(g, path, e.Name(), tracked, rep); err != nil {
+				return err
+			}
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		size, err := backup.DirSize(path)
+		if err != nil {
+			return err
+		}
+		rep.Untracked = append(rep.Untracked, Untracked{
+			Name:      e.Name(),
+			Path:      path,
+			Profile:   profile,
+			SizeBytes: size,
+			ModTime:   info.ModTime().UTC(),
+		})
+	}
+	return nil
+}
+
+// audit records a record pruned or adopted by the scan in the audit log.
+func (s *Scanner) audit(ctx context.Context, action string, b *model.Backup, errCode string) {
+	if err := s.Repo.Audit.Record(ctx, s.Actor, action, b, errCode); err != nil {
+		log.Printf("audit append failed: %v", err)
+	}
+}
+
+// refreshLastBackupAt sets the game's last backup time from its newest
+// remaining backup, or clears it when none is left.
+func (s *Scanner) refreshLastBackupAt(ctx context.Context, gameID int64) error {
+	latest, err := s.Repo.Backups.GetLatestByGameID(ctx, gameID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return s.Repo.Games.UpdateLastBackupAt(ctx, gameID, time.Time{})
+	}
+	if err != nil {
+		return err
+	}
+	return s.Repo.Games.UpdateLastBackupAt(ctx, gameID, latest.CreatedAt)
+}
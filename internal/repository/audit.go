@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"gamebk/internal/model"
+)
+
+// AuditRepository stores the audit log. It is append-only: entries are never
+// changed or deleted.
+type AuditRepository struct {
+	db *bbolt.DB
+}
+
+// Append stores a new entry, setting its id.
+func (r *AuditRepository) Append(ctx context.Context, e *model.AuditEntry) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		audit := tx.Bucket([]byte(bucketAudit))
+		if audit == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		seq, err := audit.NextSequence()
+		if err != nil {
+			return err
+		}
+		e.ID = int64(seq)
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return audit.Put(putUint64(nil, seq), data)
+	})
+}
+
+// Record appends an entry for an operation on a backup made outside an API
+// request, such as the background purge or a CLI run. A non-empty errCode
+// marks it failed.
+func (r *AuditRepository) Record(ctx context.Context, actor, action string, b *model.Backup, errCode string) error {
+	e := &model.AuditEntry{
+		Time:      time.Now().UTC(),
+		Actor:     actor,
+		Action:    action,
+		GameID:    b.GameID,
+		BackupID:  b.ID,
+		Outcome:   model.AuditSuccess,
+		ErrorCode: errCode,
+		Bytes:     b.SizeBytes,
+	}
+	if errCode != "" {
+		e.Outcome = model.AuditFailure
+	}
+	return r.Append(ctx, e)
+}
+
+// Page returns up to limit entries with ids above after, oldest first, in a
+// read transaction of its own.
+func (r *AuditRepository) Page(ctx context.Context, after int64, limit int) ([]model.AuditEntry, error) {
+	var out []model.AuditEntry
+	if err := r.db.View(func(tx *bbolt.Tx) error {
+		audit := tx.Bucket([]byte(bucketAudit))
+		if audit == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		c := audit.Cursor()
+		for k, v := c.Seek(putUint64(nil, uint64(after)+1)); k != nil && len(out) < limit; k, v = c.Next() {
+			var e model.AuditEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			out = append(out, e)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Count returns the number of entries in the log. Entries are never deleted,
+// so it is the last id handed out.
+func (r *AuditRepository) Count(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		audit := tx.Bucket([]byte(bucketAudit))
+		if audit == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		n = int(audit.Sequence())
+		return nil
+	})
+	return n, err
+}
+
+// WalkBefore calls fn for the entries with ids below before, newest first,
+// inside one read transaction; before 0 starts at the newest entry. It stops
+// once fn returns false.
+func (r *AuditRepository) WalkBefore(ctx context.Context, before int64, fn func(e *model.AuditEntry) bool) error {
+	return r.db.View(func(tx *bbolt.Tx) error {
+		audit := tx.Bucket([]byte(bucketAudit))
+		if audit == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		c := audit.Cursor()
+		k, v := c.Last()
+		// Seek lands on the first id at or above before, so step back from it;
+		// past the end every id is below before
+		if before > 0 {
+			if sk, _ := c.Seek(putUint64(nil, uint64(before))); sk != nil {
+				k, v = c.Prev()
+			} else {
+				k, v = c.Last()
+			}
+		}
+		for ; k != nil; k, v = c.Prev() {
+			var e model.AuditEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if !fn(&e) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
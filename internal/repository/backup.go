@@ -3,7 +3,6 @@ package repository
 import (
 	"context"
 	"encoding/json"
-	"sort"
 
 	"go.etcd.io/bbolt"
 
@@ -14,8 +13,12 @@ type BackupRepository struct {
 	db *bbolt.DB
 }
 
+// Create stores a new backup. CreatedAt is kept when already set, so backups
+// received from another instance retain their original time.
 func (r *BackupRepository) Create(ctx context.Context, b *model.Backup) error {
-	b.CreatedAt = now()
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = now()
+	}
 	return r.db.Update(func(tx *bbolt.Tx) error {
 		meta := tx.Bucket([]byte(bucketMeta))
 		backups := tx.Bucket([]byte(bucketBackups))
@@ -30,10 +33,14 @@ func (r *BackupRepository) Create(ctx context.Context, b *model.Backup) error {
 		if err != nil {
 			return err
 		}
-		return backups.Put(putUint64(nil, next), data)
+		if err := backups.Put(putUint64(nil, next), data); err != nil {
+			return err
+		}
+		return indexBackup(tx, b)
 	})
 }
 
+// ListByGameID 返回游戏的所有备份，按创建时间倒序
 func (r *BackupRepository) ListByGameID(ctx context.Context, gameID int64) ([]model.Backup, error) {
 	var out []model.Backup
 	if err := r.db.View(func(tx *bbolt.Tx) error {
@@ -41,23 +48,44 @@ func (r *BackupRepository) ListByGameID(ctx context.Context, gameID int64) ([]mo
 		if backups == nil {
 			return bbolt.ErrBucketNotFound
 		}
-		return backups.ForEach(func(k, v []byte) error {
-			var b model.Backup
-			if err := json.Unmarshal(v, &b); err != nil {
+		idx, err := gameIndex(tx, gameID, false)
+		if err != nil || idx == nil {
+			return err
+		}
+		c := idx.Cursor()
+		for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+			b, err := loadIndexed(backups, k)
+			if err != nil {
 				return err
 			}
-			if b.GameID == gameID {
-				out = append(out, b)
+			out = append(out, *b)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SizeByGame returns the total size of the recorded backups of every game
+// that has any, as kept in the backup index.
+func (r *BackupRepository) SizeByGame(ctx context.Context) (map[int64]int64, error) {
+	out := make(map[int64]int64)
+	if err := r.db.View(func(tx *bbolt.Tx) error {
+		root := tx.Bucket([]byte(bucketBackupIndex))
+		if root == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		return root.ForEach(func(k, v []byte) error {
+			// game buckets have nil values
+			if v != nil && len(k) == 12 && len(v) == 8 {
+				out[int64(getUint64(k[:8]))] = int64(getUint64(v))
 			}
 			return nil
 		})
 	}); err != nil {
 		return nil, err
 	}
-
-	sort.Slice(out, func(i, j int) bool {
-		return out[i].CreatedAt.After(out[j].CreatedAt)
-	})
 	return out, nil
 }
 
@@ -86,60 +114,143 @@ func (r *BackupRepository) GetByID(ctx context.Context, id int64) (*model.Backup
 }
 
 func (r *BackupRepository) GetLatestByGameID(ctx context.Context, gameID int64) (*model.Backup, error) {
-	list, err := r.ListByGameID(ctx, gameID)
-	if err != nil {
+	var b *model.Backup
+	if err := r.db.View(func(tx *bbolt.Tx) error {
+		backups := tx.Bucket([]byte(bucketBackups))
+		if backups == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		idx, err := gameIndex(tx, gameID, false)
+		if err != nil {
+			return err
+		}
+		if idx == nil {
+			return ErrNotFound
+		}
+		k, _ := idx.Cursor().Last()
+		if k == nil {
+			return ErrNotFound
+		}
+		b, err = loadIndexed(backups, k)
+		return err
+	}); err != nil {
 		return nil, err
 	}
-	if len(list) == 0 {
-		return nil, ErrNotFound
+	return b, nil
+}
+
+// GetLatestByProfile returns the newest backup of one of the game's profiles.
+func (r *BackupRepository) GetLatestByProfile(ctx context.Context, gameID int64, profile string) (*model.Backup, error) {
+	var b *model.Backup
+	if err := r.db.View(func(tx *bbolt.Tx) error {
+		backups := tx.Bucket([]byte(bucketBackups))
+		if backups == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		idx, err := gameIndex(tx, gameID, false)
+		if err != nil {
+			return err
+		}
+		if idx == nil {
+			return ErrNotFound
+		}
+		c := idx.Cursor()
+		for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+			obj, err := loadIndexed(backups, k)
+			if err != nil {
+				return err
+			}
+			if obj.ProfileName() == profile {
+				b = obj
+				return nil
+			}
+		}
+		return ErrNotFound
+	}); err != nil {
+		return nil, err
 	}
-	return &list[0], nil
+	return b, nil
 }
 
-func (r *BackupRepository) DeleteByID(ctx context.Context, id int64) error {
+// Update stores the editable fields of a backup: notes, tags and the pinned
+// flag.
+func (r *BackupRepository) Update(ctx context.Context, b *model.Backup) error {
 	return r.db.Update(func(tx *bbolt.Tx) error {
 		backups := tx.Bucket([]byte(bucketBackups))
 		if backups == nil {
 			return bbolt.ErrBucketNotFound
 		}
-		key := putUint64(nil, uint64(id))
-		if backups.Get(key) == nil {
+		key := putUint64(nil, uint64(b.ID))
+		v := backups.Get(key)
+		if v == nil {
 			return ErrNotFound
 		}
-		return backups.Delete(key)
+		var existing model.Backup
+		if err := json.Unmarshal(v, &existing); err != nil {
+			return err
+		}
+		existing.Notes = b.Notes
+		existing.Tags = b.Tags
+		existing.Pinned = b.Pinned
+		data, err := json.Marshal(&existing)
+		if err != nil {
+			return err
+		}
+		if err := backups.Put(key, data); err != nil {
+			return err
+		}
+		*b = existing
+		return nil
 	})
 }
 
-// DeleteAllByGameID 删除指定游戏的所有备份记录
-func (r *BackupRepository) DeleteAllByGameID(ctx context.Context, gameID int64) error {
+func (r *BackupRepository) DeleteByID(ctx context.Context, id int64) error {
 	return r.db.Update(func(tx *bbolt.Tx) error {
 		backups := tx.Bucket([]byte(bucketBackups))
 		if backups == nil {
 			return bbolt.ErrBucketNotFound
 		}
-
-		// 收集所有需要删除的备份ID
-		var ids []uint64
-		if err := backups.ForEach(func(k, v []byte) error {
-			var b model.Backup
-			if err := json.Unmarshal(v, &b); err != nil {
-				return err
-			}
-			if b.GameID == gameID {
-				ids = append(ids, getUint64(k))
-			}
-			return nil
-		}); err != nil {
+		key := putUint64(nil, uint64(id))
+		v := backups.Get(key)
+		if v == nil {
+			return ErrNotFound
+		}
+		var b model.Backup
+		if err := json.Unmarshal(v, &b); err != nil {
 			return err
 		}
-
-		// 删除所有收集到的备份
-		for _, id := range ids {
-			if err := backups.Delete(putUint64(nil, id)); err != nil {
-				return err
-			}
+		if err := unindexBackup(tx, &b); err != nil {
+			return err
 		}
+		return backups.Delete(key)
+	})
+}
 
-		return nil
+// DeleteAllByGameID 删除指定游戏的所有备份记录
+func (r *BackupRepository) DeleteAllByGameID(ctx context.Context, gameID int64) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		return deleteGameBackups(tx, gameID)
 	})
 }
+
+// deleteGameBackups 通过索引删除游戏的所有备份记录以及索引本身
+func deleteGameBackups(tx *bbolt.Tx, gameID int64) error {
+	backups := tx.Bucket([]byte(bucketBackups))
+	if backups == nil {
+		return bbolt.ErrBucketNotFound
+	}
+	idx, err := gameIndex(tx, gameID, false)
+	if err != nil || idx == nil {
+		return err
+	}
+	if err := idx.ForEach(func(k, _ []byte) error {
+		return backups.Delete(k[8:])
+	}); err != nil {
+		return err
+	}
+	root := tx.Bucket([]byte(bucketBackupIndex))
+	if err := root.Delete(sizeKey(gameID)); err != nil {
+		return err
+	}
+	return root.DeleteBucket(putUint64(nil, uint64(gameID)))
+}
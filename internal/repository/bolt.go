@@ -12,11 +12,20 @@ const (
 	bucketMeta    = "meta"
 	bucketGames   = "games"
 	bucketBackups = "backups"
+
+	bucketBackupIndex = "backups_by_game"
+	bucketPeers       = "peers"
+	bucketSyncStates  = "sync_states"
+	bucketTrash       = "trash"
+	bucketAudit       = "audit"
+	bucketGameHistory = "game_history"
 )
 
 const (
 	keyNextGameID   = "next_game_id"
 	keyNextBackupID = "next_backup_id"
+	keyNextPeerID   = "next_peer_id"
+	keyNextTrashID  = "next_trash_id"
 )
 
 func nextID(current []byte) uint64 {
@@ -33,7 +33,10 @@ func (r *GameRepository) Create(ctx context.Context, g *model.Game) error {
 		if err != nil {
 			return err
 		}
-		return games.Put(putUint64(nil, next), data)
+		if err := games.Put(putUint64(nil, next), data); err != nil {
+			return err
+		}
+		return recordVersion(tx, nil, g, model.GameVersionCreate, 0)
 	})
 }
 
@@ -88,53 +91,124 @@ func (r *GameRepository) GetByID(ctx context.Context, id int64) (*model.Game, er
 }
 
 func (r *GameRepository) UpdateLastBackupAt(ctx context.Context, gameID int64, t time.Time) error {
-	return r.db.Update(func(tx *bbolt.Tx) error {
-		games := tx.Bucket([]byte(bucketGames))
-		if games == nil {
-			return bbolt.ErrBucketNotFound
-		}
-		key := putUint64(nil, uint64(gameID))
-		v := games.Get(key)
-		if v == nil {
-			return ErrNotFound
-		}
-		var g model.Game
-		if err := json.Unmarshal(v, &g); err != nil {
-			return err
-		}
+	return r.modify(gameID, func(g *model.Game) {
 		g.LastBackupAt = &t
-		g.UpdatedAt = now()
-		data, err := json.Marshal(&g)
+	})
+}
+
+// UpdateProfiles stores the active profile and the named profiles of a game.
+func (r *GameRepository) UpdateProfiles(ctx context.Context, gameID int64, active string, profiles []string) error {
+	return r.modify(gameID, func(g *model.Game) {
+		g.Profile = active
+		g.Profiles = profiles
+	})
+}
+
+// AddProfile adds a named profile to a game unless it has it already, for
+// backups arriving with a profile the game does not know yet.
+func (r *GameRepository) AddProfile(ctx context.Context, gameID int64, name string) error {
+	return r.modify(gameID, func(g *model.Game) {
+		if !g.HasProfile(name) {
+			g.Profiles = append(g.Profiles, name)
+		}
+	})
+}
+
+// UpdateIcon stores the file name of the game's icon, or clears it.
+func (r *GameRepository) UpdateIcon(ctx context.Context, gameID int64, icon string) error {
+	return r.modify(gameID, func(g *model.Game) {
+		g.Icon = icon
+	})
+}
+
+// Update stores the editable fields of g: its configuration and metadata.
+// A changed configuration is recorded as a new version in the game history.
+func (r *GameRepository) Update(ctx context.Context, g *model.Game) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		before, after, err := modifyGame(tx, g.ID, func(existing *model.Game) {
+			existing.ApplyConfig(g.Config())
+		})
 		if err != nil {
 			return err
 		}
-		return games.Put(key, data)
+		return recordVersion(tx, before, after, model.GameVersionUpdate, 0)
 	})
 }
 
-func (r *GameRepository) Update(ctx context.Context, g *model.Game) error {
+// modify loads a game, applies fn and stores it with a new UpdatedAt in one
+// transaction.
+func (r *GameRepository) modify(gameID int64, fn func(g *model.Game)) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		_, _, err := modifyGame(tx, gameID, fn)
+		return err
+	})
+}
+
+// modifyGame applies fn to a stored game inside tx and returns the game as it
+// was before and after.
+func modifyGame(tx *bbolt.Tx, gameID int64, fn func(g *model.Game)) (before, after *model.Game, err error) {
+	games := tx.Bucket([]byte(bucketGames))
+	if games == nil {
+		return nil, nil, bbolt.ErrBucketNotFound
+	}
+	key := putUint64(nil, uint64(gameID))
+	v := games.Get(key)
+	if v == nil {
+		return nil, nil, ErrNotFound
+	}
+	before, after = &model.Game{}, &model.Game{}
+	if err := json.Unmarshal(v, before); err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(v, after); err != nil {
+		return nil, nil, err
+	}
+	fn(after)
+	after.UpdatedAt = now()
+	data, err := json.Marshal(after)
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, games.Put(key, data)
+}
+
+// Delete 删除游戏及其所有备份记录和同步状态，在同一个事务中完成
+func (r *GameRepository) Delete(ctx context.Context, id int64) error {
 	return r.db.Update(func(tx *bbolt.Tx) error {
 		games := tx.Bucket([]byte(bucketGames))
-		if games == nil {
+		states := tx.Bucket([]byte(bucketSyncStates))
+		if games == nil || states == nil {
 			return bbolt.ErrBucketNotFound
 		}
-		key := putUint64(nil, uint64(g.ID))
-		v := games.Get(key)
-		if v == nil {
+		key := putUint64(nil, uint64(id))
+		if games.Get(key) == nil {
 			return ErrNotFound
 		}
-		var existing model.Game
-		if err := json.Unmarshal(v, &existing); err != nil {
+		if err := games.Delete(key); err != nil {
 			return err
 		}
-		existing.Name = g.Name
-		existing.GamePath = g.GamePath
-		existing.BackupRoot = g.BackupRoot
-		existing.UpdatedAt = now()
-		data, err := json.Marshal(&existing)
-		if err != nil {
+
+		if err := deleteGameBackups(tx, id); err != nil {
+			return err
+		}
+		if err := deleteGameHistory(tx, id); err != nil {
+			return err
+		}
+
+		var stateKeys [][]byte
+		if err := states.ForEach(func(k, v []byte) error {
+			if len(k) == 16 && getUint64(k[8:]) == uint64(id) {
+				stateKeys = append(stateKeys, append([]byte(nil), k...))
+			}
+			return nil
+		}); err != nil {
 			return err
 		}
-		return games.Put(key, data)
+		for _, k := range stateKeys {
+			if err := states.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 }
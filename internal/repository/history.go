@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"go.etcd.io/bbolt"
+
+	"gamebk/internal/model"
+)
+
+// Game history keys are the game id followed by the version number, both
+// big-endian, so one game's versions are contiguous and in order.
+func historyKey(gameID, version int64) []byte {
+	k := make([]byte, 16)
+	putUint64(k[:8], uint64(gameID))
+	putUint64(k[8:], uint64(version))
+	return k
+}
+
+// History lists a game's configuration versions, newest first.
+func (r *GameRepository) History(ctx context.Context, gameID int64) ([]model.GameVersion, error) {
+	var out []model.GameVersion
+	prefix := putUint64(nil, uint64(gameID))
+	if err := r.db.View(func(tx *bbolt.Tx) error {
+		history := tx.Bucket([]byte(bucketGameHistory))
+		if history == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		c := history.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var ver model.GameVersion
+			if err := json.Unmarshal(v, &ver); err != nil {
+				return err
+			}
+			out = append(out, ver)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
+
+// GetVersion returns one configuration version of a game.
+func (r *GameRepository) GetVersion(ctx context.Context, gameID, version int64) (*model.GameVersion, error) {
+	var ver *model.GameVersion
+	if err := r.db.View(func(tx *bbolt.Tx) error {
+		history := tx.Bucket([]byte(bucketGameHistory))
+		if history == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		v := history.Get(historyKey(gameID, version))
+		if v == nil {
+			return ErrNotFound
+		}
+		var obj model.GameVersion
+		if err := json.Unmarshal(v, &obj); err != nil {
+			return err
+		}
+		ver = &obj
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ver, nil
+}
+
+// Revert puts back the configuration of a prior version, recording it as a
+// new version. Nothing is recorded when the configuration is already the
+// same.
+func (r *GameRepository) Revert(ctx context.Context, gameID, version int64) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		history := tx.Bucket([]byte(bucketGameHistory))
+		if history == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		v := history.Get(historyKey(gameID, version))
+		if v == nil {
+			return ErrNotFound
+		}
+		var target model.GameVersion
+		if err := json.Unmarshal(v, &target); err != nil {
+			return err
+		}
+		before, after, err := modifyGame(tx, gameID, func(g *model.Game) {
+			g.ApplyConfig(target.Config)
+		})
+		if err != nil {
+			return err
+		}
+		return recordVersion(tx, before, after, model.GameVersionRevert, version)
+	})
+}
+
+// recordVersion stores the configuration of after as the game's next version
+// when it differs from before. before is nil for a new game.
+func recordVersion(tx *bbolt.Tx, before, after *model.Game, reason string, revertOf int64) error {
+	history := tx.Bucket([]byte(bucketGameHistory))
+	if history == nil {
+		return bbolt.ErrBucketNotFound
+	}
+	changes := []model.GameChange{}
+	if before != nil {
+		changes = model.DiffGameConfig(before.Config(), after.Config())
+		if len(changes) == 0 {
+			return nil
+		}
+	}
+
+	// the last key before the next game's first version is this game's latest
+	var version int64 = 1
+	c := history.Cursor()
+	k, _ := c.Seek(putUint64(nil, uint64(after.ID)+1))
+	if k == nil {
+		k, _ = c.Last()
+	} else {
+		k, _ = c.Prev()
+	}
+	if k != nil && len(k) == 16 && getUint64(k[:8]) == uint64(after.ID) {
+		version = int64(getUint64(k[8:])) + 1
+	}
+
+	data, err := json.Marshal(&model.GameVersion{
+		GameID:   after.ID,
+		Version:  version,
+		Time:     after.UpdatedAt,
+		Reason:   reason,
+		RevertOf: revertOf,
+		Config:   after.Config(),
+		Changes:  changes,
+	})
+	if err != nil {
+		return err
+	}
+	return history.Put(historyKey(after.ID, version), data)
+}
+
+// deleteGameHistory removes every version of a game.
+func deleteGameHistory(tx *bbolt.Tx, gameID int64) error {
+	history := tx.Bucket([]byte(bucketGameHistory))
+	if history == nil {
+		return bbolt.ErrBucketNotFound
+	}
+	prefix := putUint64(nil, uint64(gameID))
+	var keys [][]byte
+	c := history.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	for _, k := range keys {
+		if err := history.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
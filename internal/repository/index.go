@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"gamebk/internal/model"
+)
+
+// The backup index keeps one nested bucket per game under bucketBackupIndex.
+// Keys are indexTime(created_at) followed by the backup id, both big endian,
+// so a cursor walks a game's backups in creation order without touching the
+// backups of other games. Values are empty. Next to the game buckets, the
+// root holds each game's total backup size under sizeKey.
+
+func backupIndexKey(b *model.Backup) []byte {
+	key := make([]byte, 16)
+	putUint64(key[:8], indexTime(b.CreatedAt))
+	putUint64(key[8:], uint64(b.ID))
+	return key
+}
+
+var (
+	minIndexTime = time.Unix(0, math.MinInt64)
+	maxIndexTime = time.Unix(0, math.MaxInt64)
+)
+
+// indexTime maps t to an unsigned key in time order: unix nanoseconds with
+// the sign bit flipped, so times before 1970 sort first. Times out of the
+// int64 range, such as the zero time, are clamped to the ends.
+func indexTime(t time.Time) uint64 {
+	n := t.UnixNano()
+	switch {
+	case t.Before(minIndexTime):
+		n = math.MinInt64
+	case t.After(maxIndexTime):
+		n = math.MaxInt64
+	}
+	return uint64(n) ^ 1<<63
+}
+
+// gameIndex returns the index bucket of a game. When create is false a
+// missing bucket yields nil without error.
+func gameIndex(tx *bbolt.Tx, gameID int64, create bool) (*bbolt.Bucket, error) {
+	root := tx.Bucket([]byte(bucketBackupIndex))
+	if root == nil {
+		return nil, bbolt.ErrBucketNotFound
+	}
+	name := putUint64(nil, uint64(gameID))
+	if create {
+		return root.CreateBucketIfNotExists(name)
+	}
+	return root.Bucket(name), nil
+}
+
+func indexBackup(tx *bbolt.Tx, b *model.Backup) error {
+	idx, err := gameIndex(tx, b.GameID, true)
+	if err != nil {
+		return err
+	}
+	key := backupIndexKey(b)
+	if idx.Get(key) != nil {
+		return nil
+	}
+	if err := idx.Put(key, []byte{}); err != nil {
+		return err
+	}
+	return addGameSize(tx, b.GameID, b.SizeBytes)
+}
+
+func unindexBackup(tx *bbolt.Tx, b *model.Backup) error {
+	idx, err := gameIndex(tx, b.GameID, false)
+	if err != nil || idx == nil {
+		return err
+	}
+	key := backupIndexKey(b)
+	if idx.Get(key) == nil {
+		return nil
+	}
+	if err := idx.Delete(key); err != nil {
+		return err
+	}
+	return addGameSize(tx, b.GameID, -b.SizeBytes)
+}
+
+// sizeKey is the root index key of a game's total backup size. It is longer
+// than the game bucket names, so the two never clash.
+func sizeKey(gameID int64) []byte {
+	return append(putUint64(nil, uint64(gameID)), "size"...)
+}
+
+// addGameSize adjusts a game's total backup size by delta.
+func addGameSize(tx *bbolt.Tx, gameID, delta int64) error {
+	root := tx.Bucket([]byte(bucketBackupIndex))
+	if root == nil {
+		return bbolt.ErrBucketNotFound
+	}
+	key := sizeKey(gameID)
+	var total int64
+	if v := root.Get(key); len(v) == 8 {
+		total = int64(getUint64(v))
+	}
+	total += delta
+	if total <= 0 {
+		return root.Delete(key)
+	}
+	return root.Put(key, putUint64(nil, uint64(total)))
+}
+
+func loadIndexed(backups *bbolt.Bucket, indexKey []byte) (*model.Backup, error) {
+	v := backups.Get(indexKey[8:])
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	var b model.Backup
+	if err := json.Unmarshal(v, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
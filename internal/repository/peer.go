@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"gamebk/internal/model"
+)
+
+type PeerRepository struct {
+	db *bbolt.DB
+}
+
+func (r *PeerRepository) Create(ctx context.Context, p *model.Peer) error {
+	p.CreatedAt = now()
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		meta := tx.Bucket([]byte(bucketMeta))
+		peers := tx.Bucket([]byte(bucketPeers))
+		if meta == nil || peers == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		next := nextID(meta.Get([]byte(keyNextPeerID)))
+		meta.Put([]byte(keyNextPeerID), putUint64(nil, next))
+		p.ID = int64(next)
+
+		data, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		return peers.Put(putUint64(nil, next), data)
+	})
+}
+
+func (r *PeerRepository) List(ctx context.Context) ([]model.Peer, error) {
+	var out []model.Peer
+	if err := r.db.View(func(tx *bbolt.Tx) error {
+		peers := tx.Bucket([]byte(bucketPeers))
+		if peers == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		return peers.ForEach(func(k, v []byte) error {
+			var p model.Peer
+			if err := json.Unmarshal(v, &p); err != nil {
+				return err
+			}
+			out = append(out, p)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *PeerRepository) GetByID(ctx context.Context, id int64) (*model.Peer, error) {
+	var p *model.Peer
+	key := putUint64(nil, uint64(id))
+	if err := r.db.View(func(tx *bbolt.Tx) error {
+		peers := tx.Bucket([]byte(bucketPeers))
+		if peers == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		v := peers.Get(key)
+		if v == nil {
+			return ErrNotFound
+		}
+		var obj model.Peer
+		if err := json.Unmarshal(v, &obj); err != nil {
+			return err
+		}
+		p = &obj
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *PeerRepository) UpdateLastSyncAt(ctx context.Context, id int64, t time.Time) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		peers := tx.Bucket([]byte(bucketPeers))
+		if peers == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		key := putUint64(nil, uint64(id))
+		v := peers.Get(key)
+		if v == nil {
+			return ErrNotFound
+		}
+		var p model.Peer
+		if err := json.Unmarshal(v, &p); err != nil {
+			return err
+		}
+		p.LastSyncAt = &t
+		data, err := json.Marshal(&p)
+		if err != nil {
+			return err
+		}
+		return peers.Put(key, data)
+	})
+}
+
+// DeleteByID 删除 peer 及其所有同步状态
+func (r *PeerRepository) DeleteByID(ctx context.Context, id int64) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		peers := tx.Bucket([]byte(bucketPeers))
+		states := tx.Bucket([]byte(bucketSyncStates))
+		if peers == nil || states == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		key := putUint64(nil, uint64(id))
+		if peers.Get(key) == nil {
+			return ErrNotFound
+		}
+		if err := peers.Delete(key); err != nil {
+			return err
+		}
+
+		var keys [][]byte
+		c := states.Cursor()
+		for k, _ := c.Seek(key); k != nil && len(k) == 16 && getUint64(k[:8]) == uint64(id); k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := states.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetSyncState 返回 peer 与游戏的同步状态，没有同步过时返回 ErrNotFound
+func (r *PeerRepository) GetSyncState(ctx context.Context, peerID, gameID int64) (*model.SyncState, error) {
+	var s *model.SyncState
+	if err := r.db.View(func(tx *bbolt.Tx) error {
+		states := tx.Bucket([]byte(bucketSyncStates))
+		if states == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		v := states.Get(syncStateKey(peerID, gameID))
+		if v == nil {
+			return ErrNotFound
+		}
+		var obj model.SyncState
+		if err := json.Unmarshal(v, &obj); err != nil {
+			return err
+		}
+		s = &obj
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *PeerRepository) PutSyncState(ctx context.Context, s *model.SyncState) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		states := tx.Bucket([]byte(bucketSyncStates))
+		if states == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		return states.Put(syncStateKey(s.PeerID, s.GameID), data)
+	})
+}
+
+func syncStateKey(peerID, gameID int64) []byte {
+	key := make([]byte, 16)
+	putUint64(key[:8], uint64(peerID))
+	putUint64(key[8:], uint64(gameID))
+	return key
+}
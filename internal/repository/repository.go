@@ -6,6 +6,9 @@ type Repository struct {
 	DB      *bbolt.DB
 	Games   *GameRepository
 	Backups *BackupRepository
+	Peers   *PeerRepository
+	Trash   *TrashRepository
+	Audit   *AuditRepository
 }
 
 func New(db *bbolt.DB) *Repository {
@@ -13,5 +16,8 @@ func New(db *bbolt.DB) *Repository {
 		DB:      db,
 		Games:   &GameRepository{db: db},
 		Backups: &BackupRepository{db: db},
+		Peers:   &PeerRepository{db: db},
+		Trash:   &TrashRepository{db: db},
+		Audit:   &AuditRepository{db: db},
 	}
 }
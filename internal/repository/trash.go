@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"go.etcd.io/bbolt"
+
+	"gamebk/internal/model"
+)
+
+// ErrBackupExists is returned when a trashed backup is restored while a
+// record with its id exists again.
+var ErrBackupExists = errors.New("backup already exists")
+
+type TrashRepository struct {
+	db *bbolt.DB
+}
+
+// Trash removes the backup record and records it in the trash in one
+// transaction. The caller has moved the folder to trashPath already.
+func (r *TrashRepository) Trash(ctx context.Context, b *model.Backup, trashPath string) (*model.TrashedBackup, error) {
+	item := &model.TrashedBackup{Backup: *b, TrashPath: trashPath, DeletedAt: now()}
+	if err := r.db.Update(func(tx *bbolt.Tx) error {
+		meta := tx.Bucket([]byte(bucketMeta))
+		backups := tx.Bucket([]byte(bucketBackups))
+		trash := tx.Bucket([]byte(bucketTrash))
+		if meta == nil || backups == nil || trash == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		key := putUint64(nil, uint64(b.ID))
+		if backups.Get(key) == nil {
+			return ErrNotFound
+		}
+		if err := unindexBackup(tx, b); err != nil {
+			return err
+		}
+		if err := backups.Delete(key); err != nil {
+			return err
+		}
+
+		next := nextID(meta.Get([]byte(keyNextTrashID)))
+		meta.Put([]byte(keyNextTrashID), putUint64(nil, next))
+		item.ID = int64(next)
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		return trash.Put(putUint64(nil, next), data)
+	}); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// List 返回回收站中的所有备份，按删除时间倒序
+func (r *TrashRepository) List(ctx context.Context) ([]model.TrashedBackup, error) {
+	var out []model.TrashedBackup
+	if err := r.db.View(func(tx *bbolt.Tx) error {
+		trash := tx.Bucket([]byte(bucketTrash))
+		if trash == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		c := trash.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var item model.TrashedBackup
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			out = append(out, item)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *TrashRepository) GetByID(ctx context.Context, id int64) (*model.TrashedBackup, error) {
+	var item *model.TrashedBackup
+	if err := r.db.View(func(tx *bbolt.Tx) error {
+		trash := tx.Bucket([]byte(bucketTrash))
+		if trash == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		v := trash.Get(putUint64(nil, uint64(id)))
+		if v == nil {
+			return ErrNotFound
+		}
+		var obj model.TrashedBackup
+		if err := json.Unmarshal(v, &obj); err != nil {
+			return err
+		}
+		item = &obj
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Restore puts a trashed backup record back under its original id and drops
+// it from the trash in one transaction. The caller moves the folder back
+// first.
+func (r *TrashRepository) Restore(ctx context.Context, id int64) (*model.Backup, error) {
+	var b *model.Backup
+	if err := r.db.Update(func(tx *bbolt.Tx) error {
+		backups := tx.Bucket([]byte(bucketBackups))
+		trash := tx.Bucket([]byte(bucketTrash))
+		if backups == nil || trash == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		key := putUint64(nil, uint64(id))
+		v := trash.Get(key)
+		if v == nil {
+			return ErrNotFound
+		}
+		var item model.TrashedBackup
+		if err := json.Unmarshal(v, &item); err != nil {
+			return err
+		}
+		backupKey := putUint64(nil, uint64(item.Backup.ID))
+		if backups.Get(backupKey) != nil {
+			return ErrBackupExists
+		}
+		data, err := json.Marshal(&item.Backup)
+		if err != nil {
+			return err
+		}
+		if err := backups.Put(backupKey, data); err != nil {
+			return err
+		}
+		if err := indexBackup(tx, &item.Backup); err != nil {
+			return err
+		}
+		b = &item.Backup
+		return trash.Delete(key)
+	}); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Delete drops a trash record for good.
+func (r *TrashRepository) Delete(ctx context.Context, id int64) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		trash := tx.Bucket([]byte(bucketTrash))
+		if trash == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		key := putUint64(nil, uint64(id))
+		if trash.Get(key) == nil {
+			return ErrNotFound
+		}
+		return trash.Delete(key)
+	})
+}
@@ -1,19 +1,25 @@
 package router
 
 import (
+	"fmt"
 	"io/fs"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"go.etcd.io/bbolt"
 
 	"gamebk/internal/config"
 	"gamebk/internal/handler"
 	webui "gamebk/web"
 )
 
-func New(cfg config.Config, db *bbolt.DB) *gin.Engine {
+// New returns the engine serving the web UI and the API of h.
+func New(cfg config.Config, h *handler.Handler) *gin.Engine {
 	r := gin.Default()
+	// the client IP is recorded in the audit log, so forwarded headers are
+	// only believed from configured proxies
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		panic(fmt.Errorf("invalid GAMEBK_TRUSTED_PROXIES: %w", err))
+	}
 
 	sub, err := fs.Sub(webui.FS, ".")
 	if err != nil {
@@ -28,18 +34,65 @@ func New(cfg config.Config, db *bbolt.DB) *gin.Engine {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
-	h := handler.New(db)
 	api := r.Group("/api/v1")
+	api.Use(h.Audit())
 	{
 		api.POST("/games", h.CreateGame)
 		api.PATCH("/games/:id", h.UpdateGame)
+		api.DELETE("/games/:id", h.DeleteGame)
+		api.GET("/games/:id/history", h.GameHistory)
+		api.GET("/games/:id/history/:version", h.GetGameVersion)
+		api.POST("/games/:id/history/:version/revert", h.RevertGame)
+		api.GET("/games/:id/icon", h.GetIcon)
+		api.PUT("/games/:id/icon", h.UploadIcon)
+		api.DELETE("/games/:id/icon", h.DeleteIcon)
 		api.POST("/games/:id/backup", h.BackupGame)
 		api.POST("/games/:id/restore/latest", h.RestoreLatest)
 		api.POST("/games/:id/restore/:backupId", h.RestoreByID)
 		api.GET("/games", h.ListGames)
 		api.GET("/games/:id/backups", h.ListBackups)
+		api.PATCH("/games/:id/backups/:backupId", h.UpdateBackup)
 		api.DELETE("/games/:id/backups/:backupId", h.DeleteBackup)
 		api.DELETE("/games/:id/backups", h.DeleteAllBackups)
+		api.GET("/games/:id/backups/:backupId/download", h.DownloadBackup)
+		api.POST("/games/:id/backups/upload", h.UploadBackup)
+		api.GET("/games/:id/backups/:backupId/archive", h.DownloadBackupArchive)
+		api.POST("/games/:id/backups/archive", h.ReceiveBackupArchive)
+		api.GET("/games/:id/backups/:backupId/files", h.ListBackupFiles)
+		api.GET("/games/:id/backups/:backupId/file", h.DownloadBackupFile)
+		api.GET("/games/:id/backups/:backupId/diff", h.DiffBackup)
+		api.POST("/games/:id/backups/import", h.ImportBackups)
+		api.GET("/games/:id/profiles", h.ListProfiles)
+		api.POST("/games/:id/profiles", h.CreateProfile)
+		api.DELETE("/games/:id/profiles/:profile", h.DeleteProfile)
+		api.POST("/games/:id/profiles/:profile/switch", h.SwitchProfile)
+
+		api.GET("/audit", h.ListAudit)
+		api.GET("/audit/export", h.ExportAudit)
+
+		api.GET("/trash", h.ListTrash)
+		api.POST("/trash/:trashId/restore", h.RestoreTrash)
+		api.DELETE("/trash/:trashId", h.PurgeTrash)
+
+		api.GET("/export", h.ExportLibrary)
+		api.POST("/import", h.ImportLibrary)
+
+		api.GET("/manifest", h.ManifestStatus)
+		api.PUT("/manifest", h.UploadManifest)
+		api.GET("/manifest/games", h.SearchManifest)
+		api.GET("/manifest/detect", h.DetectGames)
+
+		api.GET("/steam/apps", h.ListSteamApps)
+		api.GET("/steam/apps/:appId", h.GetSteamApp)
+
+		api.GET("/reconcile", h.ScanBackups)
+		api.POST("/reconcile", h.ReconcileBackups)
+
+		api.POST("/peers", h.CreatePeer)
+		api.GET("/peers", h.ListPeers)
+		api.DELETE("/peers/:peerId", h.DeletePeer)
+		api.GET("/peers/:peerId/status", h.PeerSyncStatus)
+		api.POST("/peers/:peerId/sync", h.SyncPeer)
 	}
 
 	return r
@@ -0,0 +1,216 @@
+// Package steam reads a local Steam installation: its library folders, the
+// apps installed in them and the per-user userdata save folders.
+package steam
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrAppNotFound is returned when an app is not installed in any library.
+var ErrAppNotFound = errors.New("steam app not installed")
+
+// App is an installed Steam app.
+type App struct {
+	ID   int64  `json:"app_id"`
+	Name string `json:"name"`
+	// InstallDir is the folder name below steamapps/common.
+	InstallDir  string `json:"install_dir"`
+	LibraryPath string `json:"library_path"`
+	InstallPath string `json:"install_path"`
+	// UserData lists userdata/<user id>/<app id> folders, most recently
+	// modified first.
+	UserData []UserData `json:"userdata"`
+}
+
+// UserData is the save folder of one Steam account for an app.
+type UserData struct {
+	UserID     string    `json:"user_id"`
+	Path       string    `json:"path"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// Installation is a Steam root directory.
+type Installation struct {
+	Root string
+}
+
+// Libraries returns the library folders listed in
+// steamapps/libraryfolders.vdf, always starting with the Steam root itself.
+// Both the current and the pre-2021 file layout are understood.
+func (s Installation) Libraries() ([]string, error) {
+	out := []string{filepath.Clean(s.Root)}
+	seen := map[string]bool{out[0]: true}
+	f, err := os.Open(filepath.Join(s.Root, "steamapps", "libraryfolders.vdf"))
+	if errors.Is(err, os.ErrNotExist) {
+		return out, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	root, err := ParseVDF(f)
+	if err != nil {
+		return nil, fmt.Errorf("libraryfolders.vdf: %w", err)
+	}
+	folders := root.Child("libraryfolders")
+	for _, c := range folders.Children {
+		if _, err := strconv.Atoi(c.Key); err != nil {
+			continue
+		}
+		p := c.Value
+		if len(c.Children) > 0 {
+			p = c.Get("path")
+		}
+		if p == "" {
+			continue
+		}
+		p = filepath.Clean(p)
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// Apps lists the apps installed in every library, sorted by name. Libraries
+// that cannot be read, such as unplugged drives, are skipped.
+func (s Installation) Apps() ([]App, error) {
+	libs, err := s.Libraries()
+	if err != nil {
+		return nil, err
+	}
+	var out []App
+	for _, lib := range libs {
+		matches, err := filepath.Glob(filepath.Join(lib, "steamapps", "appmanifest_*.acf"))
+		if err != nil {
+			continue
+		}
+		for _, m := range matches {
+			app, err := s.readManifest(lib, m)
+			if err != nil {
+				continue
+			}
+			out = append(out, *app)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !strings.EqualFold(out[i].Name, out[j].Name) {
+			return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
+
+// App finds an installed app by id, looking it up in every library so that
+// moved installs are found in their current location.
+func (s Installation) App(id int64) (*App, error) {
+	libs, err := s.Libraries()
+	if err != nil {
+		return nil, err
+	}
+	return s.findApp(libs, id)
+}
+
+func (s Installation) findApp(libs []string, id int64) (*App, error) {
+	name := fmt.Sprintf("appmanifest_%d.acf", id)
+	for _, lib := range libs {
+		p := filepath.Join(lib, "steamapps", name)
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		return s.readManifest(lib, p)
+	}
+	return nil, fmt.Errorf("%w: %d", ErrAppNotFound, id)
+}
+
+// Cache looks up apps of an installation, reading libraryfolders.vdf and
+// each app manifest at most once. It is meant for one request touching many
+// games and is not safe for concurrent use.
+type Cache struct {
+	Installation
+	libs    []string
+	libsErr error
+	apps    map[int64]cachedApp
+}
+
+type cachedApp struct {
+	app *App
+	err error
+}
+
+// App is Installation.App with the result remembered.
+func (c *Cache) App(id int64) (*App, error) {
+	if r, ok := c.apps[id]; ok {
+		return r.app, r.err
+	}
+	if c.libs == nil && c.libsErr == nil {
+		c.libs, c.libsErr = c.Libraries()
+	}
+	var r cachedApp
+	if r.err = c.libsErr; r.err == nil {
+		r.app, r.err = c.findApp(c.libs, id)
+	}
+	if c.apps == nil {
+		c.apps = make(map[int64]cachedApp)
+	}
+	c.apps[id] = r
+	return r.app, r.err
+}
+
+func (s Installation) readManifest(lib, path string) (*App, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	root, err := ParseVDF(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	state := root.Child("AppState")
+	id, err := strconv.ParseInt(state.Get("appid"), 10, 64)
+	if err != nil || id <= 0 {
+		return nil, fmt.Errorf("%s: %w: missing appid", filepath.Base(path), ErrInvalidVDF)
+	}
+	dir := state.Get("installdir")
+	app := &App{
+		ID:          id,
+		Name:        state.Get("name"),
+		InstallDir:  dir,
+		LibraryPath: lib,
+		InstallPath: filepath.Join(lib, "steamapps", "common", dir),
+		UserData:    s.userData(id),
+	}
+	return app, nil
+}
+
+// userData lists the userdata folders of an app, newest first.
+func (s Installation) userData(id int64) []UserData {
+	out := []UserData{}
+	users, err := os.ReadDir(filepath.Join(s.Root, "userdata"))
+	if err != nil {
+		return out
+	}
+	for _, u := range users {
+		if !u.IsDir() {
+			continue
+		}
+		p := filepath.Join(s.Root, "userdata", u.Name(), strconv.FormatInt(id, 10))
+		info, err := os.Stat(p)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		out = append(out, UserData{UserID: u.Name(), Path: p, ModifiedAt: info.ModTime()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ModifiedAt.After(out[j].ModifiedAt) })
+	return out
+}
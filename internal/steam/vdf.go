@@ -0,0 +1,193 @@
+package steam
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrInvalidVDF is returned for malformed KeyValues text.
+var ErrInvalidVDF = errors.New("invalid vdf")
+
+// Node is one entry of Valve's KeyValues text format: either a key with a
+// string value or a key with child nodes. Keys may repeat.
+type Node struct {
+	Key      string
+	Value    string
+	Children []*Node
+}
+
+// Child returns the first child whose key matches, ignoring case as Steam
+// does, or nil.
+func (n *Node) Child(key string) *Node {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.Children {
+		if strings.EqualFold(c.Key, key) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Get returns the value of the named child, or "".
+func (n *Node) Get(key string) string {
+	if c := n.Child(key); c != nil {
+		return c.Value
+	}
+	return ""
+}
+
+// ParseVDF reads KeyValues text and returns a root node holding the
+// top-level entries. Comments and platform conditionals such as [$WIN32]
+// are ignored.
+func ParseVDF(r io.Reader) (*Node, error) {
+	p := &vdfParser{r: bufio.NewReader(r)}
+	root := &Node{}
+	if err := p.parseChildren(root, false); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+type vdfParser struct {
+	r    *bufio.Reader
+	line int
+}
+
+const (
+	tokString = iota
+	tokOpen
+	tokClose
+	tokEOF
+)
+
+func (p *vdfParser) parseChildren(parent *Node, nested bool) error {
+	for {
+		kind, key, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case tokEOF:
+			if nested {
+				return fmt.Errorf("%w: unexpected end of input", ErrInvalidVDF)
+			}
+			return nil
+		case tokClose:
+			if !nested {
+				return fmt.Errorf("%w: unexpected } on line %d", ErrInvalidVDF, p.line+1)
+			}
+			return nil
+		case tokOpen:
+			return fmt.Errorf("%w: unexpected { on line %d", ErrInvalidVDF, p.line+1)
+		}
+
+		kind, value, err := p.next()
+		if err != nil {
+			return err
+		}
+		node := &Node{Key: key}
+		switch kind {
+		case tokString:
+			node.Value = value
+		case tokOpen:
+			if err := p.parseChildren(node, true); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: missing value for %q on line %d", ErrInvalidVDF, key, p.line+1)
+		}
+		parent.Children = append(parent.Children, node)
+	}
+}
+
+// next returns the next token, skipping whitespace, comments and
+// conditionals.
+func (p *vdfParser) next() (int, string, error) {
+	for {
+		ch, _, err := p.r.ReadRune()
+		if errors.Is(err, io.EOF) {
+			return tokEOF, "", nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		switch {
+		case ch == '\n':
+			p.line++
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\uFEFF':
+		case ch == '{':
+			return tokOpen, "", nil
+		case ch == '}':
+			return tokClose, "", nil
+		case ch == '/':
+			if next, _, err := p.r.ReadRune(); err == nil && next == '/' {
+				if _, err := p.r.ReadString('\n'); err != nil && !errors.Is(err, io.EOF) {
+					return 0, "", err
+				}
+				p.line++
+				continue
+			}
+			return 0, "", fmt.Errorf("%w: stray / on line %d", ErrInvalidVDF, p.line+1)
+		case ch == '[':
+			if _, err := p.r.ReadString(']'); err != nil {
+				return 0, "", fmt.Errorf("%w: unterminated conditional", ErrInvalidVDF)
+			}
+		case ch == '"':
+			s, err := p.quoted()
+			return tokString, s, err
+		default:
+			_ = p.r.UnreadRune()
+			return tokString, p.bare(), nil
+		}
+	}
+}
+
+func (p *vdfParser) quoted() (string, error) {
+	var b strings.Builder
+	for {
+		ch, _, err := p.r.ReadRune()
+		if err != nil {
+			return "", fmt.Errorf("%w: unterminated string", ErrInvalidVDF)
+		}
+		switch ch {
+		case '"':
+			return b.String(), nil
+		case '\n':
+			p.line++
+		case '\\':
+			esc, _, err := p.r.ReadRune()
+			if err != nil {
+				return "", fmt.Errorf("%w: unterminated string", ErrInvalidVDF)
+			}
+			switch esc {
+			case 'n':
+				ch = '\n'
+			case 't':
+				ch = '\t'
+			default:
+				ch = esc
+			}
+		}
+		b.WriteRune(ch)
+	}
+}
+
+func (p *vdfParser) bare() string {
+	var b strings.Builder
+	for {
+		ch, _, err := p.r.ReadRune()
+		if err != nil {
+			return b.String()
+		}
+		if strings.ContainsRune(" \t\r\n{}\"", ch) {
+			_ = p.r.UnreadRune()
+			return b.String()
+		}
+		b.WriteRune(ch)
+	}
+}
@@ -0,0 +1,203 @@
+package steam
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseVDF(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []*Node
+	}{
+		{
+			name: "nested",
+			in:   "\"AppState\"\n{\n\t\"appid\"\t\t\"620\"\n\t\"name\"\t\t\"Portal 2\"\n}\n",
+			want: []*Node{{Key: "AppState", Children: []*Node{
+				{Key: "appid", Value: "620"},
+				{Key: "name", Value: "Portal 2"},
+			}}},
+		},
+		{
+			name: "escapes",
+			in:   `"k" "a\"b\\c\nd\te"`,
+			want: []*Node{{Key: "k", Value: "a\"b\\c\nd\te"}},
+		},
+		{
+			name: "comments and conditionals",
+			in:   "// header\n\"k\" \"v\" [$WIN32]\n\"j\" [$!OSX] { \"x\" \"1\" } // trailing\n",
+			want: []*Node{
+				{Key: "k", Value: "v"},
+				{Key: "j", Children: []*Node{{Key: "x", Value: "1"}}},
+			},
+		},
+		{
+			name: "bare tokens",
+			in:   "root { key value }",
+			want: []*Node{{Key: "root", Children: []*Node{{Key: "key", Value: "value"}}}},
+		},
+		{
+			name: "byte order mark and CRLF",
+			in:   "\uFEFF\"k\"\r\n{\r\n\"a\" \"1\"\r\n}\r\n",
+			want: []*Node{{Key: "k", Children: []*Node{{Key: "a", Value: "1"}}}},
+		},
+		{
+			name: "repeated keys",
+			in:   `"k" "1" "k" "2"`,
+			want: []*Node{{Key: "k", Value: "1"}, {Key: "k", Value: "2"}},
+		},
+		{
+			name: "empty",
+			in:   "",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := ParseVDF(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatalf("ParseVDF: %v", err)
+			}
+			if !reflect.DeepEqual(root.Children, tt.want) {
+				t.Errorf("got %s, want %s", dump(root.Children), dump(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseVDFInvalid(t *testing.T) {
+	tests := map[string]string{
+		"unclosed block":      `"k" { "a" "1"`,
+		"stray close":         `"k" "v" }`,
+		"open as key":         `{ "k" "v" }`,
+		"missing value":       `"k"`,
+		"unterminated string": `"k" "v`,
+		"unterminated escape": `"k" "v\`,
+		"single slash":        `"k" / "v"`,
+		"unterminated cond":   `"k" "v" [$WIN32`,
+		"close as value":      `"k" { "a" }`,
+	}
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseVDF(strings.NewReader(in)); !errors.Is(err, ErrInvalidVDF) {
+				t.Errorf("err = %v, want ErrInvalidVDF", err)
+			}
+		})
+	}
+}
+
+func TestNodeChild(t *testing.T) {
+	root, err := ParseVDF(strings.NewReader(`"AppState" { "InstallDir" "Portal 2" "installdir" "other" }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := root.Child("appstate")
+	if got := app.Get("installdir"); got != "Portal 2" {
+		t.Errorf("Get = %q, want the first match ignoring case", got)
+	}
+	if got := app.Get("missing"); got != "" {
+		t.Errorf("Get missing = %q", got)
+	}
+	var nilNode *Node
+	if nilNode.Child("x") != nil || nilNode.Get("x") != "" {
+		t.Error("nil node should have no children")
+	}
+}
+
+func TestLibraries(t *testing.T) {
+	tests := []struct {
+		name string
+		vdf  string
+		want []string
+	}{
+		{
+			name: "current layout",
+			vdf: `"libraryfolders"
+{
+	"0"
+	{
+		"path"		"ROOT"
+		"apps" { "620" "123" }
+	}
+	"1"
+	{
+		"path"		"/mnt/games"
+		"label"		""
+	}
+}`,
+			want: []string{"ROOT", "/mnt/games"},
+		},
+		{
+			name: "pre-2021 layout",
+			vdf: `"LibraryFolders"
+{
+	"TimeNextStatsReport"		"1600000000"
+	"ContentStatsID"		"-123"
+	"1"		"/mnt/games"
+	"2"		"/mnt/more/"
+}`,
+			want: []string{"ROOT", "/mnt/games", "/mnt/more"},
+		},
+		{
+			name: "empty path skipped",
+			vdf:  `"libraryfolders" { "1" { "path" "" } }`,
+			want: []string{"ROOT"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "steamapps")
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			vdf := strings.ReplaceAll(tt.vdf, "ROOT", filepath.ToSlash(root))
+			if err := os.WriteFile(filepath.Join(dir, "libraryfolders.vdf"), []byte(vdf), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Installation{Root: root}.Libraries()
+			if err != nil {
+				t.Fatalf("Libraries: %v", err)
+			}
+			want := make([]string, len(tt.want))
+			for i, p := range tt.want {
+				want[i] = filepath.Clean(filepath.FromSlash(strings.ReplaceAll(p, "ROOT", filepath.ToSlash(root))))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLibrariesMissingFile(t *testing.T) {
+	root := t.TempDir()
+	got, err := Installation{Root: root}.Libraries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != filepath.Clean(root) {
+		t.Errorf("got %q, want only the root", got)
+	}
+}
+
+// dump renders nodes for failure messages.
+func dump(nodes []*Node) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		b.WriteString(strconv.Quote(n.Key))
+		if n.Children != nil {
+			b.WriteString("{" + dump(n.Children) + "}")
+		} else {
+			b.WriteString("=" + strconv.Quote(n.Value))
+		}
+		b.WriteString(" ")
+	}
+	return b.String()
+}
@@ -0,0 +1,155 @@
+// Package trash moves deleted backups aside and purges them once they have
+// been in the trash long enough.
+package trash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gamebk/internal/backup"
+	"gamebk/internal/job"
+	"gamebk/internal/model"
+	"gamebk/internal/repository"
+)
+
+// DirName is the trash folder inside each backup root. Backup names cannot
+// start with a dot, so it never clashes with a backup.
+const DirName = ".trash"
+
+// Move moves a backup folder into the trash of the game's backup root and
+// returns its new path. Folders outside the root, such as backups imported in
+// place, are moved there as well. A folder that no longer exists yields ""
+// without error.
+func Move(backupRoot, backupPath string) (string, error) {
+	if _, err := os.Lstat(backupPath); errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	dir := filepath.Join(backupRoot, DirName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dir, fmt.Sprintf("%s-%d", filepath.Base(backupPath), time.Now().UnixNano()))
+	if _, err := backup.MoveDir(backupPath, dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// Purger permanently deletes backups that have been in the trash for more
+// than Days days. Each purge runs as a trash job of the backup's game on
+// Jobs, the tracker the API restores and purges the trash under.
+type Purger struct {
+	Repo *repository.Repository
+	Jobs *job.Tracker
+	Days int
+}
+
+// PurgeAt returns when a trashed backup is due for purging, or nil when
+// purging is disabled.
+func (p *Purger) PurgeAt(item *model.TrashedBackup) *time.Time {
+	if p.Days <= 0 {
+		return nil
+	}
+	t := item.DeletedAt.AddDate(0, 0, p.Days)
+	return &t
+}
+
+// Purge deletes the trashed backups due before now and returns their trash
+// ids. Items of a game with a running job are skipped until the next run.
+// Folders are removed before their records, so a failure leaves the record
+// to be retried.
+func (p *Purger) Purge(ctx context.Context, now time.Time) ([]int64, error) {
+	if p.Days <= 0 {
+		return nil, nil
+	}
+	items, err := p.Repo.Trash.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var purged []int64
+	for i := range items {
+		if due := p.PurgeAt(&items[i]); due.After(now) {
+			continue
+		}
+		ok, err := p.purgeItem(ctx, items[i].ID, items[i].Backup.GameID, now)
+		if err != nil {
+			return purged, err
+		}
+		if ok {
+			purged = append(purged, items[i].ID)
+		}
+	}
+	return purged, nil
+}
+
+// purgeItem purges one trashed backup under its game's job. The record is
+// loaded again once the job is held, since a restore or purge through the API
+// may have taken it meanwhile. Returns false when the item was skipped.
+func (p *Purger) purgeItem(ctx context.Context, id, gameID int64, now time.Time) (bool, error) {
+	done, _, ok := p.Jobs.Start(gameID, job.KindTrash)
+	if !ok {
+		return false, nil
+	}
+	defer done()
+	item, err := p.Repo.Trash.GetByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if due := p.PurgeAt(item); due.After(now) {
+		return false, nil
+	}
+	err = Remove(ctx, p.Repo, item)
+	p.audit(ctx, item, err)
+	return err == nil, err
+}
+
+// audit records the purge of item in the audit log.
+func (p *Purger) audit(ctx context.Context, item *model.TrashedBackup, err error) {
+	code := ""
+	if err != nil {
+		code = "io_error"
+	}
+	if err := p.Repo.Audit.Record(ctx, model.AuditActorPurger, "trash.purge", &item.Backup, code); err != nil {
+		log.Printf("audit append failed: %v", err)
+	}
+}
+
+// Run purges once right away and then every interval until ctx is done.
+func (p *Purger) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		purged, err := p.Purge(ctx, time.Now())
+		if err != nil {
+			log.Printf("trash purge failed: %v", err)
+		} else if len(purged) > 0 {
+			log.Printf("trash purge removed %d backups", len(purged))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Remove deletes a trashed backup for good: its folder, then its record.
+func Remove(ctx context.Context, repo *repository.Repository, item *model.TrashedBackup) error {
+	if item.TrashPath != "" {
+		if err := os.RemoveAll(item.TrashPath); err != nil {
+			return err
+		}
+	}
+	if err := repo.Trash.Delete(ctx, item.ID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	return nil
+}
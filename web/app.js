@@ -233,7 +233,10 @@ function selectGame(game) {
     <div><strong>ID:</strong> ${game.id}</div>
     <div><strong>Name:</strong> ${game.name}</div>
     <div><strong>Game Path:</strong> ${game.game_path}</div>
+    <div><strong>Resolved Game Path:</strong> ${game.resolved_game_path || "-"}</div>
     <div><strong>Backup Root:</strong> ${game.backup_root}</div>
+    <div><strong>Resolved Backup Root:</strong> ${game.resolved_backup_root || "-"}</div>
+    ${game.path_error ? `<div><strong>Path Error:</strong> ${game.path_error}</div>` : ""}
     <div><strong>Last Backup:</strong> ${game.last_backup_at ?? "-"}</div>
     <div class="pill">Selected</div>
   `;
@@ -101,7 +101,7 @@ async function fetchGames() {
   if (res.ok && res.data && res.data.data) {
     const rows = res.data.data.map((g) => ({
       ...g,
-      actions: `<button class="btn-inline" data-open="${g.id}">打开</button> <button class="btn-inline" data-edit="${g.id}">编辑</button>`,
+      actions: `<button class="btn-inline" data-open="${g.id}">打开</button> <button class="btn-inline" data-edit="${g.id}">编辑</button> <button class="btn-inline" data-delete="${g.id}">删除</button>`,
     }));
     renderTable(gamesTable, rows, [
       { key: "id", label: "ID" },
@@ -131,6 +131,19 @@ function wireLinks(games) {
       if (game) openModal("update", game);
     });
   });
+  gamesTable.querySelectorAll("button[data-delete]").forEach((btn) => {
+    btn.addEventListener("click", async () => {
+      const id = btn.getAttribute("data-delete");
+      const game = games.find((g) => String(g.id) === String(id));
+      if (!game || !confirm(`确定删除游戏「${game.name}」吗？`)) return;
+      const removeFiles = confirm("是否同时删除磁盘上的备份文件？");
+      const res = await request("DELETE", `/api/v1/games/${id}?remove_files=${removeFiles}`);
+      if (!res.ok) {
+        alert(res.data?.message ?? `删除失败 (${res.status})`);
+      }
+      await fetchGames();
+    });
+  });
 }
 
 async function handleHealth() {